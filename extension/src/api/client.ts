@@ -1,4 +1,4 @@
-import type { AnalyzeRequest, AppConfig, AnalyzeResponse, UserSettings, ApiResponse } from "../types";
+import type { AnalyzeRequest, AppConfig, AnalyzeResponse, JobStatus, SubtreeResponse, UserSettings, ApiResponse } from "../types";
 
 // Base server URL (without /api path)
 const DEFAULT_SERVER_URL = "http://localhost:8080";
@@ -141,11 +141,36 @@ export const apiClient = {
             method: "POST",
             headers: { "Content-Type": "application/json" },
             body: JSON.stringify({
+                ...params.filters,
                 repo_url: params.repoURL,
                 branch: params.branch,
             }),
         }),
 
+    // 2.5 提交异步分析任务，之后通过 getJob 轮询进度和结果
+    // Content Script 的请求经 Background Script 代理，无法使用 SSE，这里采用轮询
+    submitJob: (params: AnalyzeRequest) =>
+        http<JobStatus>("/jobs", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({
+                ...params.filters,
+                repo_url: params.repoURL,
+                branch: params.branch,
+            }),
+        }),
+
+    getJob: (id: string) => http<JobStatus>(`/jobs/${encodeURIComponent(id)}`),
+
+    cancelJob: (id: string) =>
+        http<JobStatus>(`/jobs/${encodeURIComponent(id)}`, { method: "DELETE" }),
+
+    // 2.6 获取子目录树，用于展开超出分析深度的目录；gitRef 为分支名或提交 SHA
+    getTree: (repo: string, gitRef: string, path: string, depth = 1) => {
+        const query = new URLSearchParams({ repo, ref: gitRef, path, depth: String(depth) });
+        return http<SubtreeResponse>(`/tree?${query}`);
+    },
+
     // 3. 更新设置
     updateSettings: async (settings: Partial<UserSettings>) => {
         await saveSettings(settings);
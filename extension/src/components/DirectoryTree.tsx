@@ -3,6 +3,7 @@ import type { TreeNode, Summary } from "@/types";
 import {
     Folder,
     ChevronDown,
+    Loader2,
     Code2,
     MessageSquare,
     AlignJustify,
@@ -13,6 +14,7 @@ import { cn } from "@/lib/utils";
 import { Tooltip } from "./Tooltip";
 
 import { LANG_CONFIG } from "@/utils/languages";
+import { apiClient } from "@/api/client";
 
 // 辅助函数：格式化数字 (1234 -> 1.2k) - 这是一个简化的版本，如果需要全数字则用 formatNum
 const formatNum = (num: number) => new Intl.NumberFormat().format(num);
@@ -55,18 +57,48 @@ const FileIcon = ({ language, isDark }: { language?: string, isDark: boolean })
     );
 };
 
+// 超出分析深度的目录在展开时从服务端加载
+interface TreeSource {
+    repo: string;
+    gitRef: string; // 分支名或提交 SHA
+}
+
 interface DirectoryTreeProps {
     data: TreeNode;
     theme?: 'light' | 'dark';
+    source?: TreeSource;
 }
 
-function TreeItem({ node, level = 0, theme = 'light' }: { node: TreeNode; level?: number; theme?: 'light' | 'dark' }) {
+function TreeItem({ node, level = 0, theme = 'light', source }: { node: TreeNode; level?: number; theme?: 'light' | 'dark'; source?: TreeSource }) {
     const [isOpen, setIsOpen] = useState(level === 0);
+    const [loadedChildren, setLoadedChildren] = useState<Record<string, TreeNode> | null>(null);
+    const [isLoading, setIsLoading] = useState(false);
     const isDir = node.type === "dir";
     const isDark = theme === 'dark';
 
-    const hasChildren = node.children && Object.keys(node.children).length > 0;
-    const sortedChildren = useMemo(() => sortChildren(node.children), [node.children]);
+    const children = loadedChildren ?? node.children;
+    const hasChildren = children && Object.keys(children).length > 0;
+    // 目录有内容但没有子节点，说明被分析深度截断
+    const canLoad = isDir && !hasChildren && !!source && node.stats.lines > 0;
+    const sortedChildren = useMemo(() => sortChildren(children), [children]);
+
+    const handleClick = async () => {
+        if (!isDir || isLoading) return;
+        if (canLoad && source) {
+            setIsLoading(true);
+            try {
+                const subtree = await apiClient.getTree(source.repo, source.gitRef, node.path);
+                setLoadedChildren(subtree.data.children ?? {});
+                setIsOpen(true);
+            } catch (e) {
+                console.error('Failed to load subtree:', e);
+            } finally {
+                setIsLoading(false);
+            }
+            return;
+        }
+        setIsOpen(!isOpen);
+    };
 
     // 缩进配置
     const INDENT_SIZE = 24; // 每层缩进像素
@@ -90,12 +122,14 @@ function TreeItem({ node, level = 0, theme = 'light' }: { node: TreeNode; level?
                     "flex items-center gap-1.5 py-1 pr-2 rounded-md cursor-pointer group transition-colors relative",
                     isDark ? "hover:bg-gray-800/50" : "hover:bg-gray-100/70"
                 )}
-                onClick={() => isDir && setIsOpen(!isOpen)}
+                onClick={handleClick}
                 style={{ paddingLeft: `${level * INDENT_SIZE + 12}px` }}
             >
                 {/* 箭头 */}
                 <div className="flex-shrink-0 w-4 h-4 flex items-center justify-center transition-transform duration-200" style={{ transform: isOpen ? 'rotate(0deg)' : 'rotate(-90deg)' }}>
-                    {isDir && hasChildren ? (
+                    {isLoading ? (
+                        <Loader2 className={`w-3.5 h-3.5 animate-spin ${isDark ? 'text-gray-500' : 'text-gray-400'}`} />
+                    ) : isDir && (hasChildren || canLoad) ? (
                         <ChevronDown className={`w-3.5 h-3.5 ${isDark ? 'text-gray-500' : 'text-gray-400'}`} />
                     ) : <div className="w-3.5 h-3.5" />}
                 </div>
@@ -129,6 +163,7 @@ function TreeItem({ node, level = 0, theme = 'light' }: { node: TreeNode; level?
                             node={child}
                             level={level + 1}
                             theme={theme}
+                            source={source}
                         />
                     ))}
                 </div>
@@ -195,10 +230,10 @@ function StatsBadges({ stats, theme }: { stats: Summary; theme?: 'light' | 'dark
     );
 }
 
-export function DirectoryTree({ data, theme = 'light' }: DirectoryTreeProps) {
+export function DirectoryTree({ data, theme = 'light', source }: DirectoryTreeProps) {
     return (
         <div className="space-y-0.5 select-none text-sm font-medium">
-            <TreeItem node={data} level={0} theme={theme} />
+            <TreeItem node={data} level={0} theme={theme} source={source} />
         </div>
     );
 }
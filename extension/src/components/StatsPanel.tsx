@@ -4,6 +4,30 @@ import { X, ChevronDown, ChevronUp, Sun, Moon, Github, Loader2, AlertCircle, Ref
 import { OverviewStats } from './OverviewStats';
 import { LanguageStats } from './LanguageStats';
 import { DirectoryTree } from './DirectoryTree';
+import type { ProgressEvent } from '../types';
+
+// 分析进度的描述文字
+function describeProgress(progress: ProgressEvent | null): string {
+    if (!progress) return '正在分析仓库...';
+    switch (progress.stage) {
+        case 'queued':
+            return progress.position ? `排队中，前面还有 ${progress.position - 1} 个分析` : '排队中...';
+        case 'metadata':
+            return '正在检查仓库信息...';
+        case 'cloning':
+            return progress.phase
+                ? `正在克隆仓库：${progress.phase} ${progress.percent ?? 0}%`
+                : '正在克隆仓库...';
+        case 'counting': {
+            const parts = [];
+            if (progress.files) parts.push(`${progress.files.toLocaleString()} 个文件`);
+            if (progress.lines) parts.push(`${progress.lines.toLocaleString()} 行`);
+            return parts.length > 0 ? `正在统计代码：${parts.join('，')}` : '正在统计代码...';
+        }
+        case 'building':
+            return '正在生成目录树...';
+    }
+}
 
 export function StatsPanel({ onClose }: { onClose?: () => void }) {
     const {
@@ -16,6 +40,7 @@ export function StatsPanel({ onClose }: { onClose?: () => void }) {
         setPanelWidth,
         status,
         error,
+        progress,
         reset
     } = useGoLocStore();
     const [activeTab, setActiveTab] = useState<'overview' | 'languages' | 'tree'>('overview');
@@ -213,7 +238,7 @@ export function StatsPanel({ onClose }: { onClose?: () => void }) {
                         <div className="p-8 flex flex-col items-center justify-center min-h-[200px]">
                             <Loader2 className={`w-12 h-12 animate-spin mb-4 ${isDark ? 'text-blue-400' : 'text-blue-500'}`} />
                             <p className={`text-sm font-medium ${isDark ? 'text-gray-300' : 'text-gray-700'}`}>
-                                正在分析仓库...
+                                {describeProgress(progress)}
                             </p>
                             <p className={`text-xs mt-2 ${isDark ? 'text-gray-500' : 'text-gray-500'}`}>
                                 大型项目可能需要较长时间
@@ -299,7 +324,11 @@ export function StatsPanel({ onClose }: { onClose?: () => void }) {
                                 )}
 
                                 {activeTab === 'tree' && (
-                                    <DirectoryTree data={result.data} theme={effectiveTheme} />
+                                    <DirectoryTree
+                                        data={result.data}
+                                        theme={effectiveTheme}
+                                        source={{ repo: result.repo, gitRef: result.commit || result.branch }}
+                                    />
                                 )}
                             </div>
                         </>
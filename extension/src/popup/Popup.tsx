@@ -339,6 +339,36 @@ export function Popup() {
                                         checked={config.include_documentation}
                                         onChange={(checked) => setConfig({ ...config, include_documentation: checked })}
                                     />
+                                    <Toggle
+                                        label="统计标记文件"
+                                        description="包含 HTML、Handlebars、JSP 等标记/模板文件"
+                                        checked={config.include_markup}
+                                        onChange={(checked) => setConfig({ ...config, include_markup: checked })}
+                                    />
+                                    <Toggle
+                                        label="统计样式文件"
+                                        description="包含 CSS、Sass、LESS 等样式表"
+                                        checked={config.include_style}
+                                        onChange={(checked) => setConfig({ ...config, include_style: checked })}
+                                    />
+                                    <Toggle
+                                        label="统计构建脚本"
+                                        description="包含 Makefile、CMake、Dockerfile 等构建文件"
+                                        checked={config.include_build}
+                                        onChange={(checked) => setConfig({ ...config, include_build: checked })}
+                                    />
+                                    <Toggle
+                                        label="统计测试代码"
+                                        description="包含 *_test.go、*.spec.ts、tests/ 等测试文件"
+                                        checked={config.include_tests}
+                                        onChange={(checked) => setConfig({ ...config, include_tests: checked })}
+                                    />
+                                    <Toggle
+                                        label="统计依赖锁文件"
+                                        description="包含 package-lock.json、yarn.lock、go.sum 等锁文件"
+                                        checked={config.include_lockfiles}
+                                        onChange={(checked) => setConfig({ ...config, include_lockfiles: checked })}
+                                    />
                                 </div>
                             </FormSection>
 
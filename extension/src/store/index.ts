@@ -1,5 +1,5 @@
 import { create } from 'zustand';
-import type { UserSettings, AnalyzeStatus, AnalyzeResponse, AppConfig } from '../types';
+import type { UserSettings, AnalyzeStatus, AnalyzeResponse, AppConfig, ProgressEvent } from '../types';
 import { apiClient, getSettings, saveSettings } from '../api/client';
 
 interface PageState {
@@ -31,6 +31,8 @@ interface GoLocStore {
     status: AnalyzeStatus;
     error: string | null;
     result: AnalyzeResponse | null;
+    progress: ProgressEvent | null; // 分析中的进度
+    jobId: string | null;           // 进行中的分析任务
 
     // 配置
     config: AppConfig | null;
@@ -52,6 +54,16 @@ interface GoLocStore {
     getCurrentPageKey: () => string;
 }
 
+// 轮询分析任务的间隔（毫秒）
+const JOB_POLL_INTERVAL = 1000;
+
+// 取消不再需要的分析任务，没有其他请求等待同一分析时服务端会终止克隆
+const cancelRunningJob = (jobId: string | null) => {
+    if (jobId) {
+        apiClient.cancelJob(jobId).catch(() => { /* 任务可能已结束 */ });
+    }
+};
+
 const getSystemTheme = (): 'light' | 'dark' => {
     if (typeof window !== 'undefined') {
         return window.matchMedia('(prefers-color-scheme: dark)').matches ? 'dark' : 'light';
@@ -70,6 +82,8 @@ export const useGoLocStore = create<GoLocStore>((set, get) => ({
     status: 'idle',
     error: null,
     result: null,
+    progress: null,
+    jobId: null,
     config: null,
     settings: null,
     pageStates: {},
@@ -174,21 +188,39 @@ export const useGoLocStore = create<GoLocStore>((set, get) => ({
     },
 
     analyze: async (repoUrl, branch) => {
-        set({ status: 'loading', error: null });
+        cancelRunningJob(get().jobId);
+        set({ status: 'loading', error: null, progress: null, jobId: null });
         try {
-            const result = await apiClient.analyze({ repoURL: repoUrl, branch });
-            set({ status: 'success', result });
+            // 提交任务后轮询进度，直到任务结束
+            let job = await apiClient.submitJob({ repoURL: repoUrl, branch });
+            set({ jobId: job.id });
+            while (job.state === 'queued' || job.state === 'cloning' || job.state === 'counting') {
+                set({ progress: job.progress ?? null });
+                await new Promise((resolve) => setTimeout(resolve, JOB_POLL_INTERVAL));
+                // 已被新的分析或 reset 取代
+                if (get().jobId !== job.id) return;
+                job = await apiClient.getJob(job.id);
+            }
+            if (get().jobId !== job.id) return;
+            if (job.state !== 'done' || !job.result) {
+                throw new Error(job.error?.message || '分析失败');
+            }
+            const result = job.result;
+            set({ status: 'success', result, progress: null, jobId: null });
             // 分析成功后不自动展开面板，需用户手动点击
             // get().setPanelExpanded(true);
         } catch (e) {
             set({
                 status: 'error',
                 error: e instanceof Error ? e.message : 'Unknown error',
+                progress: null,
+                jobId: null,
             });
         }
     },
 
     reset: () => {
-        set({ status: 'idle', error: null, result: null });
+        cancelRunningJob(get().jobId);
+        set({ status: 'idle', error: null, result: null, progress: null, jobId: null });
     },
 }));
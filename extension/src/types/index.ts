@@ -24,6 +24,7 @@ export interface TreeNode {
     path: string;
     language?: string; // 只有 type="file" 时才有
     stats: Summary;
+    files?: number; // 节点下的文件数
     languages?: LanguageStat[]; // 目录的语言统计
     children?: Record<string, TreeNode>; // 对应 Go 的 map[string]*Node
 }
@@ -35,8 +36,18 @@ export interface AppConfig {
     request_timeout_seconds: number;
     max_repo_size_mb: number;
     exclude_dirs: string[];
+    include_patterns: string[];       // gitignore 风格的包含规则
+    exclude_patterns: string[];       // gitignore 风格的排除规则
     include_data_files: boolean;      // 是否统计数据文件（JSON/XML/YAML等）
     include_documentation: boolean;   // 是否统计文档文件（Markdown/TXT等）
+    include_markup: boolean;          // 是否统计标记/模板文件（HTML等）
+    include_style: boolean;           // 是否统计样式文件（CSS/Sass等）
+    include_build: boolean;           // 是否统计构建脚本（Makefile/CMake等）
+    include_tests: boolean;           // 是否统计测试代码
+    include_lockfiles: boolean;       // 是否统计依赖锁文件（package-lock.json/go.sum等）
+    language_categories: Record<string, string>; // 语言分类覆盖表
+    test_patterns: string[];          // 测试文件路径规则
+    use_repo_config: boolean;         // 是否读取仓库根目录的 .goloc.yml / .golocignore
 }
 
 // 用户设置
@@ -54,15 +65,128 @@ export interface UserSettings {
 export interface AnalyzeRequest {
     repoURL: string;
     branch?: string;
+    filters?: FilterOptions; // 仅对本次请求生效的过滤选项
 }
 
+// 请求级过滤选项，未设置的字段沿用后端全局配置
+export type FilterOptions = Partial<Pick<AppConfig,
+    | 'exclude_dirs'
+    | 'include_patterns'
+    | 'exclude_patterns'
+    | 'include_data_files'
+    | 'include_documentation'
+    | 'include_markup'
+    | 'include_style'
+    | 'include_build'
+    | 'include_tests'
+    | 'include_lockfiles'
+    | 'language_categories'
+    | 'test_patterns'
+    | 'use_repo_config'
+>>;
+
 export interface AnalyzeResponse {
-    source: string;
+    source: 'live' | 'cache' | 'stale'; // stale：缓存已过期或分支有新提交，服务端正在后台刷新
     repo: string;
     branch: string;
+    commit?: string;           // 分析结果对应的提交
+    age_seconds: number;       // 结果距今的秒数
     timestamp: number;
     data: TreeNode;
     languages: LanguageStat[]; // 完整的语言统计（不受深度限制）
+    lockfiles: LockfileStat;   // 依赖锁文件统计
+    excluded: ExcludedReport;  // 被排除/过滤的内容
+    repo_config?: RepoConfig;  // 仓库自带的配置
+}
+
+// 按需展开的子目录树
+export interface SubtreeResponse {
+    repo: string;
+    ref: string;
+    commit?: string;
+    path: string;
+    depth: number;
+    source: string;
+    data: TreeNode;
+}
+
+// 分析进度
+export interface ProgressEvent {
+    stage: 'queued' | 'metadata' | 'cloning' | 'counting' | 'building';
+    position?: number; // 排队位置，从 1 开始
+    phase?: string;   // 克隆的子阶段，如 Receiving objects
+    percent?: number; // 克隆子阶段的百分比
+    files?: number;   // 已统计的文件数
+    lines?: number;   // 已统计的行数
+}
+
+// 异步分析任务
+export interface JobStatus {
+    id: string;
+    kind: 'analyze' | 'batch' | 'org';
+    state: 'queued' | 'cloning' | 'counting' | 'done' | 'failed' | 'canceled';
+    repo: string;
+    branch: string;
+    owner?: string; // 组织/用户级分析任务
+    priority: 'interactive' | 'batch' | 'background';
+    created_at: number;
+    started_at?: number;
+    finished_at?: number;
+    queued_ms: number;
+    elapsed_ms: number;
+    progress?: ProgressEvent;
+    result?: AnalyzeResponse;
+    error?: { class: string; message: string };
+}
+
+// 仓库根目录 .goloc.yml / .golocignore 中的配置概要（读取到的文件和各项规则数量）
+export interface RepoConfig {
+    source: string[];
+    include: number;
+    exclude: number;
+    categories: number;
+    tests: number;
+    languages: number;
+}
+
+// 被排除目录的统计（按排除规则汇总），不做语言识别
+export interface ExcludedDirStat {
+    pattern: string;
+    dirs: number;
+    files: number;
+    lines: number;
+    code: number; // 非空行数（包括注释）
+}
+
+// 被路径规则排除的文件统计
+export interface ExcludedPatternStat {
+    pattern: string;
+    files: number;
+    lines: number;
+    code: number;
+}
+
+// 被过滤分类的统计
+export interface ExcludedCategoryStat {
+    category: string;
+    files: number;
+    lines: number;
+    code: number;
+}
+
+// 被排除/过滤内容的汇总
+export interface ExcludedReport {
+    dirs: ExcludedDirStat[];
+    patterns: ExcludedPatternStat[];
+    not_included: { files: number; lines: number; code: number }; // 未命中 include_patterns 的文件
+    categories: ExcludedCategoryStat[];
+}
+
+// 依赖锁文件统计
+export interface LockfileStat {
+    skipped: boolean; // 是否被排除在统计之外
+    files: number;
+    lines: number;
 }
 
 // 分析状态
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// 分析结果来源
+const (
+	SourceLive  = "live"  // 本次请求实时分析
+	SourceCache = "cache" // 缓存命中且未过期
+	SourceStale = "stale" // 缓存已过期或分支已有新提交，先返回旧结果，后台重新分析
+)
+
+// StatsResult 读取分析结果的返回值
+type StatsResult struct {
+	Stats  *RepoStats
+	Source string
+	Age    int64 // 距离分析完成的秒数
+}
+
+// analyses 按分支合并并发的分析，实时分析和后台刷新共用
+var analyses = NewFlightGroup()
+
+// LoadRepoStats 按缓存策略获取仓库的分析结果：
+//  1. 解析分支当前提交，按提交查缓存，未过期直接返回
+//  2. 条目已过期，或分支已有新提交（按提交未命中、按分支名命中旧结果），在允许的陈旧期内返回旧结果并后台刷新
+//  3. 都未命中时实时分析，失败结果按错误类别短期缓存
+//
+// observe 非空时接收实时分析的进度，命中缓存时不会回调；分析按 ctx 中的优先级排队（见 WithPriority）
+func LoadRepoStats(ctx context.Context, repoURL string, branch string, cfg Config, observe func(ProgressEvent)) (*StatsResult, error) {
+	// 不存在或无权访问的仓库直接返回上次的失败结果，不再请求 git 和 GitHub API
+	if cached, found := checkFailure(repoURL, branchRevision(branch), cfg); found {
+		fmt.Printf("[Cache] Failure hit (%s): %s %s\n", cached.Class, repoURL, branch)
+		return nil, cached
+	}
+
+	// 解析失败（如网络抖动）时退回按分支名查缓存
+	revision := branchRevision(branch)
+	if head, err := ResolveHead(ctx, repoURL, branch); err != nil {
+		fmt.Printf("[Revision] Failed to resolve head of %s (branch: %s): %v\n", repoURL, branch, err)
+	} else {
+		revision = head.Commit
+		fmt.Printf("[Revision] %s %s -> %s\n", repoURL, head.Ref, head.Commit)
+	}
+
+	// 缓存的是未按分类过滤的完整文件列表，但分析时的排除目录会影响内容，需要计入缓存键
+	opts := cfg.AnalysisOptions()
+	cacheKey := BuildCacheKey(repoURL, revision, opts)
+	now := time.Now().Unix()
+
+	if stats, found := cache.Get(cacheKey); found {
+		age := now - stats.AnalyzedAt
+		switch {
+		case age <= cfg.CacheTTL:
+			fmt.Println("[Cache] Hit:", cacheKey)
+			return &StatsResult{Stats: stats, Source: SourceCache, Age: age}, nil
+		case age <= cfg.CacheTTL+cfg.CacheStaleTTL:
+			fmt.Printf("[Cache] Stale (expired %ds ago): %s\n", age-cfg.CacheTTL, cacheKey)
+			refreshInBackground(repoURL, branch, cfg)
+			return &StatsResult{Stats: stats, Source: SourceStale, Age: age}, nil
+		}
+	}
+
+	// 分支有新提交：返回该分支上一次的分析结果
+	if cfg.CacheStaleTTL > 0 && revision != branchRevision(branch) {
+		branchKey := BuildCacheKey(repoURL, branchRevision(branch), opts)
+		if stats, found := cache.Get(branchKey); found && now-stats.AnalyzedAt <= cfg.CacheTTL+cfg.CacheStaleTTL {
+			fmt.Printf("[Cache] Stale (branch moved %s -> %s): %s\n", shortCommit(stats.Commit), shortCommit(revision), branchKey)
+			refreshInBackground(repoURL, branch, cfg)
+			return &StatsResult{Stats: stats, Source: SourceStale, Age: now - stats.AnalyzedAt}, nil
+		}
+	}
+
+	// 超大仓库的失败结果按提交缓存
+	if revision != branchRevision(branch) {
+		if cached, found := checkFailure(repoURL, revision, cfg); found {
+			fmt.Printf("[Cache] Failure hit (%s): %s %s\n", cached.Class, repoURL, shortCommit(revision))
+			return nil, cached
+		}
+	}
+
+	fmt.Println("[Cache] Miss:", cacheKey)
+	branchKey := BuildCacheKey(repoURL, branchRevision(branch), opts)
+	stats, shared, err := analyses.Do(ctx, branchKey, func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+		return analyzeAndStore(ctx, repoURL, branch, cfg, tracker)
+	}, observe)
+	if shared {
+		fmt.Println("[Analysis] Joined in-flight analysis:", branchKey)
+	}
+	if err != nil {
+		rememberFailure(repoURL, branch, revision, err)
+		return nil, err
+	}
+	return &StatsResult{Stats: stats, Source: SourceLive, Age: time.Now().Unix() - stats.AnalyzedAt}, nil
+}
+
+// commitSHARe 完整的提交 SHA
+var commitSHARe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// LoadRefStats 按分支名或提交 SHA 读取分析结果，供目录树、文件列表等在分析结果上的查询使用
+// 分支名与 LoadRepoStats 相同（未命中时实时分析）；提交只查缓存，未命中时返回 not_found
+func LoadRefStats(ctx context.Context, repoURL string, ref string, cfg Config) (*StatsResult, error) {
+	if !commitSHARe.MatchString(ref) {
+		return LoadRepoStats(ctx, repoURL, ref, cfg, nil)
+	}
+	stats, found := cache.Get(BuildCacheKey(repoURL, ref, cfg.AnalysisOptions()))
+	if !found {
+		return nil, newAnalysisError(ErrorNotFound, "commit %s of %s has not been analyzed or has expired", shortCommit(ref), repoURL)
+	}
+	age := time.Now().Unix() - stats.AnalyzedAt
+	// 提交的内容不会变化，过期只影响是否后台刷新，这里直接返回
+	return &StatsResult{Stats: stats, Source: SourceCache, Age: age}, nil
+}
+
+// analyzeAndStore 克隆并统计仓库，成功后写入缓存
+// ctx 在所有等待者都离开时取消，此外受 RequestTimeout 限制（从获得执行槽位开始计时）
+func analyzeAndStore(ctx context.Context, repoURL string, branch string, cfg Config, tracker *Tracker) (*RepoStats, error) {
+	release, err := workers.Acquire(ctx, tracker)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errCanceled
+		}
+		return nil, err
+	}
+	defer release()
+
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.RequestTimeout)*time.Second)
+	defer cancel()
+
+	stats, err := FetchRepoStats(fetchCtx, repoURL, branch, cfg.AnalysisOptions(), tracker)
+	if err != nil {
+		// 取消时 git 被终止，返回的错误只是取消的结果，不应按克隆失败等类别缓存
+		if ctx.Err() != nil {
+			return nil, errCanceled
+		}
+		return nil, err
+	}
+	storeRepoStats(repoURL, branch, cfg, stats)
+	return stats, nil
+}
+
+// filterRepoStats 根据当前配置过滤文件（缓存的是完整数据），仓库自带的 .goloc.yml 在服务端配置之上合并
+// 返回过滤结果和合并后的配置
+func filterRepoStats(cfg Config, stats *RepoStats) (FilterResult, Config, error) {
+	effective := cfg.WithRepoConfig(stats.RepoConfig)
+	pathFilter, err := NewPathFilter(effective.IncludePatterns, effective.ExcludePatterns)
+	if err != nil {
+		return FilterResult{}, effective, newAnalysisError(ErrorInternal, "invalid repository patterns: %v", err)
+	}
+	filtered := ApplyFilters(stats, NewFileClassifier(effective), pathFilter)
+	fmt.Printf("[Filter] Applied language filter: %d -> %d files\n", len(stats.Files), len(filtered.Files))
+	return filtered, effective, nil
+}
+
+// BuildAnalyzeResult 按请求的配置过滤缓存的完整数据，生成目录树和语言统计
+func BuildAnalyzeResult(req AnalyzeRequest, cfg Config, loaded *StatsResult) (*AnalyzeResult, error) {
+	stats := loaded.Stats
+	filtered, effective, err := filterRepoStats(cfg, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := req.MaxDepth
+	if depth <= 0 {
+		depth = cfg.DefaultDepth
+	}
+	projectName := extractProjectName(req.RepoURL)
+	treeRoot := BuildTree(filtered.Files, depth, projectName)
+
+	// 计算完整的语言统计（基于所有过滤后的文件，不受深度限制）
+	languages := CalculateLanguageStats(filtered.Files)
+
+	result := &AnalyzeResult{
+		Source:    loaded.Source,
+		Repo:      req.RepoURL,
+		Branch:    req.Branch,
+		Commit:    stats.Commit,
+		Age:       loaded.Age,
+		Timestamp: time.Now().Unix(),
+		Languages: languages,
+		Lockfiles: filtered.Lockfiles,
+		Excluded:  filtered.Excluded,
+	}
+	// 格式和排序已在接收请求时校验
+	format, sortBy, _ := ParseTreeOptions(req.TreeFormat, req.TreeSort)
+	if format == TreeFormatOrdered {
+		result.Tree = OrderTree(treeRoot, sortBy, 0, 0)
+	} else {
+		result.Data = treeRoot
+	}
+	if effective.UseRepoConfig {
+		result.RepoConfig = stats.RepoConfig.Summary()
+	}
+	return result, nil
+}
+
+// storeRepoStats 写入缓存：按提交存一份供精确命中，按分支名存一份供分支移动后返回旧结果
+// 过期时间包含陈旧期，是否过期由读取时按 AnalyzedAt 判断
+func storeRepoStats(repoURL string, branch string, cfg Config, stats *RepoStats) {
+	opts := cfg.AnalysisOptions()
+	ttl := cfg.CacheTTL + cfg.CacheStaleTTL
+
+	if stats.Commit != "" {
+		cache.Set(BuildCacheKey(repoURL, stats.Commit, opts), stats, ttl)
+	}
+	cache.Set(BuildCacheKey(repoURL, branchRevision(branch), opts), stats, ttl)
+}
+
+// refreshInBackground 以后台优先级重新分析并替换缓存，与同一分支上进行中的分析合并
+func refreshInBackground(repoURL string, branch string, cfg Config) {
+	key := BuildCacheKey(repoURL, branchRevision(branch), cfg.AnalysisOptions())
+	go func() {
+		ctx := WithPriority(context.Background(), PriorityBackground)
+		stats, shared, err := analyses.Do(ctx, key, func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+			fmt.Println("[Refresh] Started:", key)
+			return analyzeAndStore(ctx, repoURL, branch, cfg, tracker)
+		}, nil)
+		if shared {
+			return
+		}
+		if err != nil {
+			fmt.Printf("[Refresh] Failed: %s: %v\n", key, err)
+			return
+		}
+		fmt.Printf("[Refresh] Done: %s (commit %s)\n", key, shortCommit(stats.Commit))
+	}()
+}
+
+// branchRevision 按分支名缓存时使用的版本标识
+func branchRevision(branch string) string {
+	return "ref:" + branch
+}
+
+// shortCommit 日志中使用的短提交号
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
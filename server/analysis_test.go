@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeGithub 本地的 GitHub API，记录仓库元数据请求次数并返回 404
+// gate 关闭前请求一直阻塞，用于让多次调用落在同一次分析中；阻塞期间分析被取消时记入 canceled
+type fakeGithub struct {
+	requests atomic.Int64
+	canceled atomic.Int64
+	gate     chan struct{}
+}
+
+// useFakeGithub 替换分析所需的全局状态，测试结束后恢复
+func useFakeGithub(t *testing.T, cfg Config) *fakeGithub {
+	t.Helper()
+	api := &fakeGithub{gate: make(chan struct{})}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/repos/") {
+			api.requests.Add(1)
+		}
+		select {
+		case <-api.gate:
+			http.NotFound(w, r)
+		case <-r.Context().Done():
+			api.canceled.Add(1)
+		}
+	}))
+
+	savedBase, savedConfig, savedCache, savedFailures, savedWorkers := githubAPIBase, appConfig, cache, failures, workers
+	githubAPIBase = server.URL
+	appConfig = &AppConfig{inner: cfg}
+	cache = NewCache(time.Hour, CacheLimits{})
+	failures = NewFailureCache(time.Hour)
+	workers = NewWorkerPool(cfg.PoolLimits())
+	t.Cleanup(func() {
+		api.open()
+		server.Close()
+		githubAPIBase, appConfig, cache, failures, workers = savedBase, savedConfig, savedCache, savedFailures, savedWorkers
+	})
+	return api
+}
+
+func (api *fakeGithub) open() {
+	select {
+	case <-api.gate:
+	default:
+		close(api.gate)
+	}
+}
+
+// staleTestConfig 缓存 60 秒内有效，之后一小时内返回陈旧结果
+func staleTestConfig() Config {
+	cfg := NewAppConfig().Get()
+	cfg.CacheTTL = 60
+	cfg.CacheStaleTTL = 3600
+	return cfg
+}
+
+// seedBranchCache 写入 age 秒前完成的分支分析结果
+// 测试用的仓库地址无法解析提交，LoadRepoStats 退回按分支名查缓存
+func seedBranchCache(repoURL string, branch string, cfg Config, age int64) {
+	stats := &RepoStats{Branch: branch, Commit: "abc", AnalyzedAt: time.Now().Unix() - age}
+	cache.Set(BuildCacheKey(repoURL, branchRevision(branch), cfg.AnalysisOptions()), stats, 24*3600)
+}
+
+func TestLoadRepoStatsFresh(t *testing.T) {
+	cfg := staleTestConfig()
+	api := useFakeGithub(t, cfg)
+	repoURL := "file:///nonexistent-goloc-test/fresh/repo"
+	seedBranchCache(repoURL, "main", cfg, 10)
+
+	result, err := LoadRepoStats(context.Background(), repoURL, "main", cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Source != SourceCache || result.Stats.Commit != "abc" {
+		t.Errorf("result = %+v, want a cache hit", result)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := api.requests.Load(); n != 0 {
+		t.Errorf("fresh hit made %d analyses, want 0", n)
+	}
+}
+
+func TestLoadRepoStatsStale(t *testing.T) {
+	cfg := staleTestConfig()
+	api := useFakeGithub(t, cfg)
+	repoURL := "file:///nonexistent-goloc-test/stale/repo"
+	seedBranchCache(repoURL, "main", cfg, 120)
+
+	// 刷新进行中再次读取同样返回陈旧结果，并与进行中的刷新合并
+	for i := 0; i < 3; i++ {
+		result, err := LoadRepoStats(context.Background(), repoURL, "main", cfg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Source != SourceStale || result.Stats.Commit != "abc" {
+			t.Errorf("result = %+v, want the stale entry", result)
+		}
+		if result.Age < 120 || result.Age > 125 {
+			t.Errorf("age = %d, want about 120", result.Age)
+		}
+	}
+	waitFor(t, func() bool { return api.requests.Load() == 1 })
+
+	key := BuildCacheKey(repoURL, branchRevision("main"), cfg.AnalysisOptions())
+	api.open()
+	waitFor(t, func() bool {
+		analyses.mu.Lock()
+		defer analyses.mu.Unlock()
+		return analyses.flights[key] == nil
+	})
+	if n := api.requests.Load(); n != 1 {
+		t.Errorf("started %d background refreshes, want 1", n)
+	}
+	// 后台刷新失败不影响已缓存的结果，也不记录失败
+	if _, found := checkFailure(repoURL, branchRevision("main"), cfg); found {
+		t.Error("failed background refresh was cached as a failure")
+	}
+}
+
+func TestLoadRepoStatsPastStaleWindow(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		staleTTL int64
+		age      int64
+	}{
+		{"past window", 3600, 60 + 3600 + 10},
+		{"stale disabled", 0, 120},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := staleTestConfig()
+			cfg.CacheStaleTTL = c.staleTTL
+			api := useFakeGithub(t, cfg)
+			api.open()
+			repoURL := "file:///nonexistent-goloc-test/" + strings.ReplaceAll(c.name, " ", "-") + "/repo"
+			seedBranchCache(repoURL, "main", cfg, c.age)
+
+			// 实时分析：本地的 API 返回 404，结果为 not_found
+			result, err := LoadRepoStats(context.Background(), repoURL, "main", cfg, nil)
+			if errorClass(err) != ErrorNotFound {
+				t.Fatalf("LoadRepoStats() = %+v, %v, want a live analysis failing with not_found", result, err)
+			}
+			if n := api.requests.Load(); n != 1 {
+				t.Errorf("made %d analyses, want 1", n)
+			}
+		})
+	}
+}
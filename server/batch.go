@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxBatchRepos 单次批量分析的仓库数上限
+const maxBatchRepos = 500
+
+// AnalyzeBatch 分析多个仓库并汇总，各仓库与单仓库分析一样使用缓存和分析队列
+// 同时发起的分析数不超过 MaxConcurrentAnalyses，避免大批量请求占满队列；重复的仓库和分支只统计一次
+// ctx 结束时未完成的仓库以 canceled 错误返回；onRepoDone 可为 nil，每个仓库结束时以已完成数和总数调用
+func AnalyzeBatch(ctx context.Context, targets []BatchTarget, cfg Config, onRepoDone func(done, total int)) *BatchResult {
+	targets = dedupeBatchTargets(targets)
+	results := make([]BatchRepoResult, len(targets))
+	files := make([][]FileStat, len(targets))
+
+	var progressMu sync.Mutex
+	completed := 0
+	repoDone := func() {
+		if onRepoDone == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		completed++
+		onRepoDone(completed, len(targets))
+	}
+
+	sem := make(chan struct{}, max(cfg.MaxConcurrentAnalyses, 1))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer repoDone()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = BatchRepoResult{Repo: target.RepoURL, Branch: target.Branch, Error: errCanceled}
+				return
+			}
+			results[i], files[i] = analyzeBatchTarget(ctx, target, cfg)
+		}()
+	}
+	wg.Wait()
+
+	batch := mergeBatchResults(results, files)
+	fmt.Printf("[Batch] Analyzed %d repos: %d succeeded, %d failed, %d lines\n", len(targets), batch.Succeeded, batch.Failed, batch.Summary.Lines)
+	return batch
+}
+
+// mergeBatchResults 汇总各仓库的结果，files[i] 为第 i 个仓库过滤后的文件，总计和语言统计只包括成功的仓库
+func mergeBatchResults(results []BatchRepoResult, files [][]FileStat) *BatchResult {
+	batch := &BatchResult{Repos: results, Timestamp: time.Now().Unix()}
+	var union []FileStat
+	for i, result := range results {
+		if result.Error != nil {
+			batch.Failed++
+			continue
+		}
+		batch.Succeeded++
+		union = append(union, files[i]...)
+	}
+	batch.Files = len(union)
+	batch.Summary = SummarizeFiles(union)
+	batch.Languages = CalculateLanguageStats(union)
+	return batch
+}
+
+// analyzeBatchTarget 分析单个仓库，返回汇总和过滤后的文件
+func analyzeBatchTarget(ctx context.Context, target BatchTarget, cfg Config) (BatchRepoResult, []FileStat) {
+	result := BatchRepoResult{Repo: target.RepoURL, Branch: target.Branch}
+
+	loaded, err := LoadRepoStats(ctx, target.RepoURL, target.Branch, cfg, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = errCanceled
+		}
+		result.Error = asAnalysisError(err)
+		return result, nil
+	}
+	filtered, _, err := filterRepoStats(cfg, loaded.Stats)
+	if err != nil {
+		result.Error = asAnalysisError(err)
+		return result, nil
+	}
+
+	result.Branch = loaded.Stats.Branch
+	result.Commit = loaded.Stats.Commit
+	result.Source = loaded.Source
+	result.Age = loaded.Age
+	result.Files = len(filtered.Files)
+	result.Summary = SummarizeFiles(filtered.Files)
+	result.Languages = CalculateLanguageStats(filtered.Files)
+	return result, filtered.Files
+}
+
+// dedupeBatchTargets 去掉重复的仓库和分支（仓库地址按缓存键的规则归一化），保留首次出现的顺序
+func dedupeBatchTargets(targets []BatchTarget) []BatchTarget {
+	seen := make(map[string]bool, len(targets))
+	result := make([]BatchTarget, 0, len(targets))
+	for _, target := range targets {
+		key := NormalizeRepoURL(target.RepoURL) + "|" + target.Branch
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, target)
+	}
+	return result
+}
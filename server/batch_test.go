@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDedupeBatchTargets(t *testing.T) {
+	targets := []BatchTarget{
+		{RepoURL: "https://github.com/a/one"},
+		{RepoURL: "https://github.com/a/two", Branch: "dev"},
+		// 地址按缓存键的规则归一化后相同
+		{RepoURL: " https://github.com/a/one.git"},
+		{RepoURL: "https://github.com/a/one/"},
+		// 同一仓库的其他分支单独统计
+		{RepoURL: "https://github.com/a/one", Branch: "dev"},
+		{RepoURL: "https://github.com/a/two", Branch: "dev"},
+	}
+	want := []BatchTarget{
+		{RepoURL: "https://github.com/a/one"},
+		{RepoURL: "https://github.com/a/two", Branch: "dev"},
+		{RepoURL: "https://github.com/a/one", Branch: "dev"},
+	}
+	if got := dedupeBatchTargets(targets); !reflect.DeepEqual(got, want) {
+		t.Errorf("dedupeBatchTargets() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergeBatchResults(t *testing.T) {
+	results := []BatchRepoResult{
+		{Repo: "https://github.com/a/one"},
+		{Repo: "https://github.com/a/two", Error: newAnalysisError(ErrorNotFound, "not found")},
+		{Repo: "https://github.com/a/three"},
+	}
+	files := [][]FileStat{
+		{
+			{Path: "main.go", Language: "Go", Code: 60, Comments: 10, Blanks: 10},
+			{Path: "app.ts", Language: "TypeScript", Code: 15, Comments: 0, Blanks: 5},
+		},
+		nil,
+		{{Path: "util.go", Language: "Go", Code: 20, Comments: 0, Blanks: 0}},
+	}
+
+	batch := mergeBatchResults(results, files)
+	if batch.Succeeded != 2 || batch.Failed != 1 || batch.Files != 3 {
+		t.Errorf("succeeded/failed/files = %d/%d/%d, want 2/1/3", batch.Succeeded, batch.Failed, batch.Files)
+	}
+	if want := (Summary{Lines: 120, Code: 95, Comments: 10, Blanks: 15}); batch.Summary != want {
+		t.Errorf("summary = %+v, want %+v", batch.Summary, want)
+	}
+	// 同一语言跨仓库合并，占比按所有成功仓库的总行数计算
+	want := []LanguageStat{
+		{Language: "Go", Files: 2, Lines: 100, Code: 80, Comments: 10, Blanks: 10},
+		{Language: "TypeScript", Files: 1, Lines: 20, Code: 15, Comments: 0, Blanks: 5},
+	}
+	wantPercent := []float64{100.0 / 120 * 100, 20.0 / 120 * 100}
+	languages := append([]LanguageStat{}, batch.Languages...)
+	for i := range languages {
+		if i < len(wantPercent) && math.Abs(languages[i].Percentage-wantPercent[i]) > 0.01 {
+			t.Errorf("%s percentage = %v, want %v", languages[i].Language, languages[i].Percentage, wantPercent[i])
+		}
+		languages[i].Percentage = 0
+	}
+	if !reflect.DeepEqual(languages, want) {
+		t.Errorf("languages = %+v, want %+v", languages, want)
+	}
+	if !reflect.DeepEqual(batch.Repos, results) {
+		t.Errorf("repos = %+v, want the per-repo results in order", batch.Repos)
+	}
+}
+
+func TestAnalyzeBatchJob(t *testing.T) {
+	cfg := staleTestConfig()
+	api := useFakeGithub(t, cfg)
+	api.open()
+	saved := jobs
+	jobs = NewJobManager(time.Hour)
+	t.Cleanup(func() { jobs = saved })
+
+	cached := "file:///nonexistent-goloc-test/batch/cached"
+	stats := &RepoStats{Branch: "main", Commit: "abc", AnalyzedAt: time.Now().Unix(), Files: []FileStat{
+		{Path: "main.go", Language: "Go", Code: 40, Comments: 5, Blanks: 5},
+	}}
+	cache.Set(BuildCacheKey(cached, branchRevision("main"), cfg.AnalysisOptions()), stats, 3600)
+
+	req := BatchAnalyzeRequest{Repos: []BatchTarget{
+		{RepoURL: cached, Branch: "main"},
+		{RepoURL: "file:///nonexistent-goloc-test/batch/missing", Branch: "main"},
+		{RepoURL: cached + ".git", Branch: "main"},
+	}}
+	job := jobs.SubmitBatch(req, cfg, PriorityBatch)
+	select {
+	case <-job.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch job did not finish")
+	}
+
+	status := job.Status()
+	if status.Kind != JobBatch || status.State != JobDone || status.Priority != PriorityBatch {
+		t.Fatalf("status = %+v, want a finished batch job", status)
+	}
+	if status.Progress == nil || status.Progress.Percent != 100 {
+		t.Errorf("progress = %+v, want 100%%", status.Progress)
+	}
+	batch, ok := status.Result.(*BatchResult)
+	if !ok {
+		t.Fatalf("result = %T, want *BatchResult", status.Result)
+	}
+	if len(batch.Repos) != 2 || batch.Succeeded != 1 || batch.Failed != 1 {
+		t.Fatalf("result = %+v, want one cached and one failed repo", batch)
+	}
+	if batch.Repos[0].Source != SourceCache || batch.Summary.Lines != 50 {
+		t.Errorf("cached repo = %+v, summary = %+v", batch.Repos[0], batch.Summary)
+	}
+	if errorClass(batch.Repos[1].Error) != ErrorNotFound {
+		t.Errorf("missing repo error = %v, want not_found", batch.Repos[1].Error)
+	}
+}
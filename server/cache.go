@@ -1,23 +1,274 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
 
+// BuildCacheKey 按仓库、版本和分析选项生成缓存键
+// revision 通常是提交 SHA，指向同一提交的多个分支共用一个条目；分支有新提交后键随之变化
+// 排除目录以规范化集合（去重、排序）的哈希计入键中，不同排除配置的结果互不影响、可以共存
+func BuildCacheKey(repoURL string, revision string, opts AnalysisOptions) string {
+	key := fmt.Sprintf("%s|%s|excl=%s", NormalizeRepoURL(repoURL), revision, excludeSetHash(opts.ExcludeDirs))
+	if !opts.UseRepoConfig {
+		key += "|norepo"
+	}
+	return key
+}
+
+// NormalizeRepoURL 规范化仓库地址（去掉末尾的 / 和 .git），缓存键和按仓库清理缓存都以此为准
+func NormalizeRepoURL(repoURL string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(repoURL), "/"), ".git")
+}
+
+// parseCacheKey 从缓存键中解析仓库和版本（提交 SHA 或 ref:分支名）
+func parseCacheKey(key string) (repo string, revision string) {
+	parts := strings.SplitN(key, "|", 3)
+	if len(parts) < 2 {
+		return key, ""
+	}
+	return parts[0], parts[1]
+}
+
+// excludeSetHash 计算排除目录集合的哈希，excludeDirs 需先经过 normalizeExcludeDirs
+func excludeSetHash(excludeDirs []string) string {
+	sum := sha1.Sum([]byte(strings.Join(excludeDirs, "\x00")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Cache 分析结果缓存，按 CacheBackend 配置选择实现
+type Cache interface {
+	Get(key string) (*RepoStats, bool)
+	Set(key string, data *RepoStats, ttlSeconds int64)
+	Delete(key string)
+	Clear()
+	Stats() CacheStats
+	List() []CacheEntryInfo
+	Info(key string) (CacheEntryInfo, bool)
+}
+
+// CacheEntryInfo 缓存条目的元数据，不包含分析结果本身
+type CacheEntryInfo struct {
+	Key       string `json:"key"`
+	Repo      string `json:"repo"`
+	Branch    string `json:"branch"`
+	Commit    string `json:"commit"`
+	Size      int64  `json:"size"` // 字节数：memory 后端为估算值（压缩条目为压缩后大小），其他后端为存储大小
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at"`
+	Age       int64  `json:"age_seconds"`
+	Hits      int64  `json:"hits"` // redis 后端不统计
+}
+
+// cacheEntryHeader 条目的元数据，disk/redis 后端写在数据前面（单独一行 JSON），列表时无需解码整个结果
+type cacheEntryHeader struct {
+	Key        string `json:"key"`
+	Repo       string `json:"repo,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	Expiration int64  `json:"expiration"` // UnixNano
+	CreatedAt  int64  `json:"created_at"` // UnixNano
+}
+
+func newCacheEntryHeader(key string, data *RepoStats, ttlSeconds int64) cacheEntryHeader {
+	now := time.Now()
+	repo, _ := parseCacheKey(key)
+	header := cacheEntryHeader{
+		Key:        key,
+		Repo:       repo,
+		Expiration: now.Add(time.Duration(ttlSeconds) * time.Second).UnixNano(),
+		CreatedAt:  now.UnixNano(),
+	}
+	if data != nil {
+		header.Branch = data.Branch
+		header.Commit = data.Commit
+	}
+	return header
+}
+
+// info 转换为对外的元数据，时间统一为 Unix 秒
+func (h cacheEntryHeader) info(size int64, hits int64) CacheEntryInfo {
+	repo := h.Repo
+	if repo == "" {
+		repo, _ = parseCacheKey(h.Key)
+	}
+	return CacheEntryInfo{
+		Key:       h.Key,
+		Repo:      repo,
+		Branch:    h.Branch,
+		Commit:    h.Commit,
+		Size:      size,
+		CreatedAt: h.CreatedAt / int64(time.Second),
+		ExpiresAt: h.Expiration / int64(time.Second),
+		Age:       int64(time.Since(time.Unix(0, h.CreatedAt)).Seconds()),
+		Hits:      hits,
+	}
+}
+
+// PurgeCache 删除满足条件的条目，返回删除数量
+func PurgeCache(c Cache, match func(CacheEntryInfo) bool) int {
+	count := 0
+	for _, info := range c.List() {
+		if match(info) {
+			c.Delete(info.Key)
+			count++
+		}
+	}
+	return count
+}
+
+// CacheStats 缓存统计信息
+type CacheStats struct {
+	Backend    string `json:"backend"`
+	Entries    int    `json:"entries"` // redis 后端不统计
+	Bytes      int64  `json:"bytes"`   // 内存后端的估算占用，其他后端不统计
+	MaxBytes   int64  `json:"max_bytes,omitempty"`
+	MaxEntries int    `json:"max_entries,omitempty"`
+	Hits       int64  `json:"hits"`
+	Misses     int64  `json:"misses"`
+	Evictions  int64  `json:"evictions"` // 因容量限制被淘汰的条目数
+	Expired    int64  `json:"expired"`   // 因 TTL 过期被清理的条目数
+}
+
+// 缓存后端类型
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendDisk   = "disk"
+	CacheBackendRedis  = "redis"
+)
+
+// NewCacheBackend 根据配置创建缓存
+func NewCacheBackend(cfg Config, cleanInterval time.Duration) (Cache, error) {
+	switch cfg.CacheBackend {
+	case "", CacheBackendMemory:
+		return NewCache(cleanInterval, CacheLimits{
+			MaxBytes:          cfg.CacheMaxMemoryMB * 1024 * 1024,
+			MaxEntries:        cfg.CacheMaxEntries,
+			CompressThreshold: cfg.CacheCompressThresholdKB * 1024,
+		}), nil
+	case CacheBackendDisk:
+		return NewDiskCache(cfg.CacheDir, cleanInterval)
+	case CacheBackendRedis:
+		return NewRedisCache(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB, cfg.RedisKeyPrefix)
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.CacheBackend)
+	}
+}
+
+// encodeRepoStats 将分析结果序列化为 gzip 压缩的 JSON，供外部存储使用
+func encodeRepoStats(data *RepoStats) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRepoStats 反序列化 encodeRepoStats 的结果
+func decodeRepoStats(raw []byte) (*RepoStats, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var data RepoStats
+	if err := json.NewDecoder(zr).Decode(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// encodeCacheEntry 序列化带元数据头的条目：首行为 JSON 头，其后为 encodeRepoStats 的结果
+func encodeCacheEntry(header cacheEntryHeader, data *RepoStats) ([]byte, error) {
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	body, err := encodeRepoStats(data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(headerBytes, '\n'), body...), nil
+}
+
+// splitCacheEntry 拆分 encodeCacheEntry 的结果；没有元数据头的旧格式条目返回空头和原始数据
+func splitCacheEntry(raw []byte) (cacheEntryHeader, []byte, error) {
+	var header cacheEntryHeader
+	if len(raw) == 0 || raw[0] != '{' {
+		return header, raw, nil
+	}
+	line, body, found := bytes.Cut(raw, []byte{'\n'})
+	if !found {
+		return header, nil, fmt.Errorf("truncated cache entry header")
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return header, nil, err
+	}
+	return header, body, nil
+}
+
+// CacheLimits 内存缓存的容量限制，0 表示不限制
+type CacheLimits struct {
+	MaxBytes          int64 // 估算的最大内存占用
+	MaxEntries        int   // 最大条目数
+	CompressThreshold int64 // 超过该估算大小的条目压缩存储
+}
+
+// estimateRepoStatsSize 估算分析结果在内存中的占用（字节）
+func estimateRepoStatsSize(data *RepoStats) int64 {
+	if data == nil {
+		return 0
+	}
+	// FileStat 结构体本身约 64 字节，另加字符串内容
+	size := int64(64)
+	for _, f := range data.Files {
+		size += 64 + int64(len(f.Path)+len(f.Language))
+	}
+	for _, d := range data.ExcludedDirs {
+		size += 64 + int64(len(d.Pattern))
+	}
+	if data.RepoConfig != nil {
+		size += 1024
+	}
+	return size
+}
+
 type CacheItem struct {
-	value      []FileStat
-	expiration int64
+	header     cacheEntryHeader
+	value      *RepoStats
+	compressed []byte // 大条目压缩后存储，value 为 nil
+	size       int64
+	hits       int64
 }
 
+// SafeCache 内存缓存：TTL 过期 + 按条目数和估算内存的 LRU 淘汰
 type SafeCache struct {
-	cache map[string]*CacheItem
-	mu    sync.RWMutex
+	cache  map[string]*list.Element
+	lru    *list.List // 队首为最近使用
+	bytes  int64
+	limits CacheLimits
+	stats  CacheStats
+	mu     sync.Mutex
 }
 
-func NewCache(cleanInterval time.Duration) *SafeCache {
+func NewCache(cleanInterval time.Duration, limits CacheLimits) *SafeCache {
 	cache := &SafeCache{
-		cache: make(map[string]*CacheItem),
+		cache:  make(map[string]*list.Element),
+		lru:    list.New(),
+		limits: limits,
 	}
 
 	go cache.startCleaner(cleanInterval)
@@ -37,42 +288,158 @@ func (c *SafeCache) cleanup() {
 	defer c.mu.Unlock()
 
 	now := time.Now().UnixNano()
-	for k, v := range c.cache {
-		if now > v.expiration {
-			delete(c.cache, k)
+	for _, elem := range c.cache {
+		if now > elem.Value.(*CacheItem).header.Expiration {
+			c.removeElement(elem)
+			c.stats.Expired++
+		}
+	}
+}
+
+// removeElement 移除条目，调用方需持有锁
+func (c *SafeCache) removeElement(elem *list.Element) {
+	item := elem.Value.(*CacheItem)
+	c.lru.Remove(elem)
+	delete(c.cache, item.header.Key)
+	c.bytes -= item.size
+}
+
+// evict 按 LRU 淘汰直到满足容量限制，调用方需持有锁
+func (c *SafeCache) evict() {
+	for c.lru.Len() > 0 {
+		overEntries := c.limits.MaxEntries > 0 && c.lru.Len() > c.limits.MaxEntries
+		overBytes := c.limits.MaxBytes > 0 && c.bytes > c.limits.MaxBytes
+		if !overEntries && !overBytes {
+			return
 		}
+		oldest := c.lru.Back()
+		fmt.Printf("[Cache] Evicted (LRU): %s\n", oldest.Value.(*CacheItem).header.Key)
+		c.removeElement(oldest)
+		c.stats.Evictions++
 	}
 }
 
-func (c *SafeCache) Set(key string, data []FileStat, ttlSeconds int64) {
+func (c *SafeCache) Set(key string, data *RepoStats, ttlSeconds int64) {
+	item := &CacheItem{
+		header: newCacheEntryHeader(key, data, ttlSeconds),
+		value:  data,
+		size:   estimateRepoStatsSize(data),
+	}
+
+	// 大条目压缩存储，压缩在锁外进行
+	if c.limits.CompressThreshold > 0 && item.size > c.limits.CompressThreshold {
+		if raw, err := encodeRepoStats(data); err == nil {
+			item.value = nil
+			item.compressed = raw
+			item.size = int64(len(raw))
+		} else {
+			fmt.Printf("[Cache] Failed to compress entry %s: %v\n", key, err)
+		}
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	exp := time.Now().Add(time.Duration(ttlSeconds) * time.Second).UnixNano()
-	c.cache[key] = &CacheItem{
-		value:      data,
-		expiration: exp,
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
 	}
+	c.cache[key] = c.lru.PushFront(item)
+	c.bytes += item.size
+	c.evict()
 }
 
-func (c *SafeCache) Get(key string) ([]FileStat, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	item, found := c.cache[key]
+func (c *SafeCache) Get(key string) (*RepoStats, bool) {
+	c.mu.Lock()
+	elem, found := c.cache[key]
 	if !found {
+		c.stats.Misses++
+		c.mu.Unlock()
 		return nil, false
 	}
-	if time.Now().UnixNano() > item.expiration {
+	item := elem.Value.(*CacheItem)
+	if time.Now().UnixNano() > item.header.Expiration {
+		c.removeElement(elem)
+		c.stats.Expired++
+		c.stats.Misses++
+		c.mu.Unlock()
 		return nil, false
 	}
+	c.lru.MoveToFront(elem)
+	item.hits++
+	c.stats.Hits++
+	c.mu.Unlock()
 
-	return item.value, true
+	if item.compressed == nil {
+		return item.value, true
+	}
+	data, err := decodeRepoStats(item.compressed)
+	if err != nil {
+		fmt.Printf("[Cache] Failed to decompress entry %s: %v\n", key, err)
+		c.Delete(key)
+		return nil, false
+	}
+	return data, true
+}
+
+// Delete 删除指定缓存
+func (c *SafeCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.cache[key]; ok {
+		c.removeElement(elem)
+	}
 }
 
 // Clear 清空所有缓存
 func (c *SafeCache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.cache = make(map[string]*CacheItem)
+	c.cache = make(map[string]*list.Element)
+	c.lru.Init()
+	c.bytes = 0
+}
+
+// Stats 返回缓存统计
+func (c *SafeCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Backend = CacheBackendMemory
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.bytes
+	stats.MaxBytes = c.limits.MaxBytes
+	stats.MaxEntries = c.limits.MaxEntries
+	return stats
+}
+
+// List 返回所有未过期条目的元数据，按最近使用排序
+func (c *SafeCache) List() []CacheEntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	infos := make([]CacheEntryInfo, 0, c.lru.Len())
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		item := elem.Value.(*CacheItem)
+		if now <= item.header.Expiration {
+			infos = append(infos, item.header.info(item.size, item.hits))
+		}
+	}
+	return infos
+}
+
+// Info 返回单个条目的元数据，不影响 LRU 顺序和命中统计
+func (c *SafeCache) Info(key string) (CacheEntryInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, found := c.cache[key]
+	if !found {
+		return CacheEntryInfo{}, false
+	}
+	item := elem.Value.(*CacheItem)
+	if time.Now().UnixNano() > item.header.Expiration {
+		return CacheEntryInfo{}, false
+	}
+	return item.header.info(item.size, item.hits), true
 }
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// diskCacheExt 缓存文件扩展名
+const diskCacheExt = ".cache"
+
+// diskCacheEntry 索引中的条目，文件首行为 cacheEntryHeader，启动时只读取首行即可重建索引
+type diskCacheEntry struct {
+	header cacheEntryHeader
+	size   int64
+	hits   int64
+}
+
+// DiskCache 基于文件的持久化缓存，每个条目一个文件，服务重启后仍然有效
+// 文件格式：首行为 JSON 头（键和过期时间），其后为 gzip 压缩的 JSON 数据
+type DiskCache struct {
+	dir   string
+	index map[string]*diskCacheEntry
+	mu    sync.RWMutex
+
+	hits    atomic.Int64
+	misses  atomic.Int64
+	expired atomic.Int64
+}
+
+// NewDiskCache 创建磁盘缓存，并从数据目录中恢复未过期的条目
+func NewDiskCache(dir string, cleanInterval time.Duration) (*DiskCache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("cache dir is required for disk cache")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %v", err)
+	}
+
+	c := &DiskCache{
+		dir:   dir,
+		index: make(map[string]*diskCacheEntry),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	go c.startCleaner(cleanInterval)
+	return c, nil
+}
+
+// load 扫描数据目录重建索引，删除过期、损坏和写入中断的文件
+func (c *DiskCache) load() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache dir: %v", err)
+	}
+
+	now := time.Now().UnixNano()
+	for _, entry := range entries {
+		name := entry.Name()
+		filePath := filepath.Join(c.dir, name)
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filePath)
+			continue
+		}
+		if entry.IsDir() || !strings.HasSuffix(name, diskCacheExt) {
+			continue
+		}
+
+		header, err := readDiskCacheHeader(filePath)
+		if err != nil || now > header.Expiration {
+			os.Remove(filePath)
+			continue
+		}
+		var size int64
+		if info, err := entry.Info(); err == nil {
+			size = info.Size()
+		}
+		c.index[header.Key] = &diskCacheEntry{header: header, size: size}
+	}
+	fmt.Printf("[Cache] Disk cache loaded %d entries from %s\n", len(c.index), c.dir)
+	return nil
+}
+
+func readDiskCacheHeader(filePath string) (cacheEntryHeader, error) {
+	var header cacheEntryHeader
+	f, err := os.Open(filePath)
+	if err != nil {
+		return header, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return header, err
+	}
+	err = json.Unmarshal(line, &header)
+	return header, err
+}
+
+// filePath 缓存键对应的文件路径
+func (c *DiskCache) filePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+diskCacheExt)
+}
+
+func (c *DiskCache) startCleaner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.cleanup()
+	}
+}
+
+func (c *DiskCache) cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	for k, v := range c.index {
+		if now > v.header.Expiration {
+			os.Remove(c.filePath(k))
+			delete(c.index, k)
+			c.expired.Add(1)
+		}
+	}
+}
+
+func (c *DiskCache) Set(key string, data *RepoStats, ttlSeconds int64) {
+	header := newCacheEntryHeader(key, data, ttlSeconds)
+
+	// 先写临时文件再重命名，避免进程中断时留下半个文件
+	target := c.filePath(key)
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(target)+".*.tmp")
+	if err != nil {
+		fmt.Printf("[Cache] Failed to create cache file: %v\n", err)
+		return
+	}
+	size, err := writeDiskCacheFile(tmp, header, data)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		fmt.Printf("[Cache] Failed to write cache file: %v\n", err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		fmt.Printf("[Cache] Failed to write cache file: %v\n", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		os.Remove(tmp.Name())
+		fmt.Printf("[Cache] Failed to commit cache file: %v\n", err)
+		return
+	}
+	c.index[key] = &diskCacheEntry{header: header, size: size}
+}
+
+// writeDiskCacheFile 写入缓存文件，返回写入的字节数
+func writeDiskCacheFile(f *os.File, header cacheEntryHeader, data *RepoStats) (int64, error) {
+	raw, err := encodeCacheEntry(header, data)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.Write(raw)
+	return int64(len(raw)), err
+}
+
+func (c *DiskCache) Get(key string) (*RepoStats, bool) {
+	c.mu.RLock()
+	entry, found := c.index[key]
+	c.mu.RUnlock()
+
+	if !found || time.Now().UnixNano() > entry.header.Expiration {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	data, err := c.read(key)
+	if err != nil {
+		fmt.Printf("[Cache] Failed to read cache file, dropping entry: %v\n", err)
+		c.Delete(key)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	atomic.AddInt64(&entry.hits, 1)
+	return data, true
+}
+
+// read 读取并解码缓存文件
+func (c *DiskCache) read(key string) (*RepoStats, error) {
+	f, err := os.Open(c.filePath(key))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	if _, err := reader.ReadBytes('\n'); err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRepoStats(body)
+}
+
+// Delete 删除指定缓存
+func (c *DiskCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	os.Remove(c.filePath(key))
+	delete(c.index, key)
+}
+
+// Clear 清空所有缓存
+func (c *DiskCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.index {
+		os.Remove(c.filePath(k))
+	}
+	c.index = make(map[string]*diskCacheEntry)
+}
+
+// Stats 返回缓存统计
+func (c *DiskCache) Stats() CacheStats {
+	c.mu.RLock()
+	entries := len(c.index)
+	c.mu.RUnlock()
+	return CacheStats{
+		Backend: CacheBackendDisk,
+		Entries: entries,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Expired: c.expired.Load(),
+	}
+}
+
+// List 返回所有未过期条目的元数据，按写入时间倒序
+func (c *DiskCache) List() []CacheEntryInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now().UnixNano()
+	infos := make([]CacheEntryInfo, 0, len(c.index))
+	for _, entry := range c.index {
+		if now <= entry.header.Expiration {
+			infos = append(infos, entry.header.info(entry.size, atomic.LoadInt64(&entry.hits)))
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt > infos[j].CreatedAt
+	})
+	return infos
+}
+
+// Info 返回单个条目的元数据
+func (c *DiskCache) Info(key string) (CacheEntryInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, found := c.index[key]
+	if !found || time.Now().UnixNano() > entry.header.Expiration {
+		return CacheEntryInfo{}, false
+	}
+	return entry.header.info(entry.size, atomic.LoadInt64(&entry.hits)), true
+}
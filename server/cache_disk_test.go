@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cacheFiles 返回目录中的文件名
+func cacheFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := &RepoStats{Branch: "main", Commit: "abc", Files: []FileStat{{Path: "main.go", Language: "Go", Code: 10}}}
+	key := "https://github.com/a/one|abc|excl=1"
+	c.Set(key, stats, 60)
+
+	got, ok := c.Get(key)
+	if !ok || !reflect.DeepEqual(got, stats) {
+		t.Fatalf("Get() = %+v, %v", got, ok)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Error("Get(missing) hit")
+	}
+	if s := c.Stats(); s.Entries != 1 || s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Stats() = %+v", s)
+	}
+	info, ok := c.Info(key)
+	if !ok || info.Repo != "https://github.com/a/one" || info.Branch != "main" || info.Commit != "abc" || info.Size == 0 {
+		t.Errorf("Info() = %+v, %v", info, ok)
+	}
+
+	// 重新打开同一目录时从文件首行重建索引
+	reopened, err := NewDiskCache(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok = reopened.Get(key)
+	if !ok || !reflect.DeepEqual(got, stats) {
+		t.Fatalf("Get() after reopen = %+v, %v", got, ok)
+	}
+	if reopenedInfo, ok := reopened.Info(key); !ok || reopenedInfo.Size != info.Size || reopenedInfo.Commit != "abc" {
+		t.Errorf("Info() after reopen = %+v, want %+v", reopenedInfo, info)
+	}
+}
+
+func TestDiskCacheExpiry(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Set("fresh", &RepoStats{Commit: "a"}, 60)
+	c.Set("stale", &RepoStats{Commit: "b"}, -1)
+	if _, ok := c.Get("stale"); ok {
+		t.Error("expired entry returned")
+	}
+	if n := len(cacheFiles(t, dir)); n != 2 {
+		t.Fatalf("%d files before cleanup, want 2", n)
+	}
+
+	c.cleanup()
+	if files := cacheFiles(t, dir); len(files) != 1 || files[0] != filepath.Base(c.filePath("fresh")) {
+		t.Errorf("files after cleanup = %v, want only the fresh entry", files)
+	}
+	if s := c.Stats(); s.Entries != 1 || s.Expired != 1 {
+		t.Errorf("Stats() = %+v", s)
+	}
+
+	// 启动时同样删除过期文件
+	c.Set("stale", &RepoStats{Commit: "b"}, -1)
+	if _, err := NewDiskCache(dir, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.filePath("stale")); !os.IsNotExist(err) {
+		t.Errorf("expired file survived reload: %v", err)
+	}
+}
+
+func TestDiskCacheIgnoresLeftoverFiles(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("key", &RepoStats{Commit: "a"}, 60)
+
+	// 写入中断留下的临时文件和损坏的缓存文件
+	tmp := filepath.Join(dir, filepath.Base(c.filePath("key"))+".123.tmp")
+	corrupt := filepath.Join(dir, strings.Repeat("0", 64)+diskCacheExt)
+	other := filepath.Join(dir, "README")
+	for _, name := range []string{tmp, corrupt, other} {
+		if err := os.WriteFile(name, []byte("garbage"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := NewDiskCache(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := reopened.Stats(); s.Entries != 1 {
+		t.Errorf("entries = %d, want 1", s.Entries)
+	}
+	if _, ok := reopened.Get("key"); !ok {
+		t.Error("valid entry lost on reload")
+	}
+	for _, name := range []string{tmp, corrupt} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("%s not cleaned up: %v", filepath.Base(name), err)
+		}
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
+
+func TestDiskCacheDeleteAndPurge(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{
+		"https://github.com/a/one|abc|excl=1",
+		"https://github.com/a/one|ref:main|excl=1",
+		"https://github.com/a/two|def|excl=1",
+	}
+	for _, key := range keys {
+		c.Set(key, &RepoStats{Commit: "abc"}, 60)
+	}
+
+	c.Delete(keys[2])
+	if _, ok := c.Get(keys[2]); ok {
+		t.Error("Get() after Delete hit")
+	}
+	if _, err := os.Stat(c.filePath(keys[2])); !os.IsNotExist(err) {
+		t.Errorf("file left after Delete: %v", err)
+	}
+
+	prefix := "https://github.com/a/one|"
+	if n := PurgeCache(c, func(info CacheEntryInfo) bool { return strings.HasPrefix(info.Key, prefix) }); n != 2 {
+		t.Errorf("PurgeCache() = %d, want 2", n)
+	}
+	if files := cacheFiles(t, dir); len(files) != 0 {
+		t.Errorf("files after purge = %v", files)
+	}
+
+	c.Set(keys[0], &RepoStats{}, 60)
+	c.Clear()
+	if list := c.List(); len(list) != 0 {
+		t.Errorf("List() after Clear = %+v", list)
+	}
+	if files := cacheFiles(t, dir); len(files) != 0 {
+		t.Errorf("files after Clear = %v", files)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// redis 连接参数
+const (
+	redisDialTimeout = 5 * time.Second
+	redisIOTimeout   = 10 * time.Second
+	redisMaxIdle     = 8
+	redisScanCount   = 500
+	redisHeaderPeek  = 1024 // 读取元数据头时最多读取的字节数
+)
+
+// RedisCache 基于 Redis 协议（RESP）的共享缓存，多个副本共用同一份分析结果
+// 值为 gzip 压缩的 JSON，过期由 Redis 的 EX 参数控制
+type RedisCache struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	idle     chan *redisConn
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewRedisCache 创建 Redis 缓存，启动时通过 PING 检查连通性
+func NewRedisCache(addr string, password string, db int, prefix string) (*RedisCache, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("redis addr is required for redis cache")
+	}
+	c := &RedisCache{
+		addr:     addr,
+		password: password,
+		db:       db,
+		prefix:   prefix,
+		idle:     make(chan *redisConn, redisMaxIdle),
+	}
+	if _, err := c.do("PING"); err != nil {
+		return nil, fmt.Errorf("failed to connect redis %s: %v", addr, err)
+	}
+	fmt.Printf("[Cache] Redis cache connected: %s (db %d, prefix %q)\n", addr, db, prefix)
+	return c, nil
+}
+
+func (c *RedisCache) Get(key string) (*RepoStats, bool) {
+	reply, err := c.do("GET", c.prefix+key)
+	if err != nil {
+		fmt.Printf("[Cache] Redis GET failed: %v\n", err)
+		c.misses.Add(1)
+		return nil, false
+	}
+	raw, ok := reply.([]byte)
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	_, body, err := splitCacheEntry(raw)
+	var data *RepoStats
+	if err == nil {
+		data, err = decodeRepoStats(body)
+	}
+	if err != nil {
+		fmt.Printf("[Cache] Failed to decode redis entry, dropping: %v\n", err)
+		c.Delete(key)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return data, true
+}
+
+func (c *RedisCache) Set(key string, data *RepoStats, ttlSeconds int64) {
+	if ttlSeconds <= 0 {
+		return
+	}
+	raw, err := encodeCacheEntry(newCacheEntryHeader(key, data, ttlSeconds), data)
+	if err != nil {
+		fmt.Printf("[Cache] Failed to encode redis entry: %v\n", err)
+		return
+	}
+	if _, err := c.do("SET", c.prefix+key, string(raw), "EX", strconv.FormatInt(ttlSeconds, 10)); err != nil {
+		fmt.Printf("[Cache] Redis SET failed: %v\n", err)
+	}
+}
+
+// Delete 删除指定缓存
+func (c *RedisCache) Delete(key string) {
+	if _, err := c.do("DEL", c.prefix+key); err != nil {
+		fmt.Printf("[Cache] Redis DEL failed: %v\n", err)
+	}
+}
+
+// Clear 清空本服务写入的缓存（按前缀扫描删除，不影响同一 Redis 中的其他数据）
+func (c *RedisCache) Clear() {
+	keys, err := c.scan(c.prefix + "*")
+	if err != nil {
+		fmt.Printf("[Cache] Redis SCAN failed: %v\n", err)
+		return
+	}
+	for start := 0; start < len(keys); start += redisScanCount {
+		end := min(start+redisScanCount, len(keys))
+		args := append([]string{"DEL"}, keys[start:end]...)
+		if _, err := c.do(args...); err != nil {
+			fmt.Printf("[Cache] Redis DEL failed: %v\n", err)
+			return
+		}
+	}
+}
+
+// Stats 返回本副本的命中统计，过期和淘汰由 Redis 自行处理
+func (c *RedisCache) Stats() CacheStats {
+	return CacheStats{
+		Backend: CacheBackendRedis,
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+}
+
+// List 返回本服务写入的所有条目的元数据，按写入时间倒序
+// 每个条目只读取开头的元数据头，不传输整个结果
+func (c *RedisCache) List() []CacheEntryInfo {
+	keys, err := c.scan(c.prefix + "*")
+	if err != nil {
+		fmt.Printf("[Cache] Redis SCAN failed: %v\n", err)
+		return []CacheEntryInfo{}
+	}
+	infos := make([]CacheEntryInfo, 0, len(keys))
+	for _, k := range keys {
+		if info, ok := c.Info(strings.TrimPrefix(k, c.prefix)); ok {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt > infos[j].CreatedAt
+	})
+	return infos
+}
+
+// Info 返回单个条目的元数据
+func (c *RedisCache) Info(key string) (CacheEntryInfo, bool) {
+	reply, err := c.do("GETRANGE", c.prefix+key, "0", strconv.Itoa(redisHeaderPeek-1))
+	if err != nil {
+		fmt.Printf("[Cache] Redis GETRANGE failed: %v\n", err)
+		return CacheEntryInfo{}, false
+	}
+	raw, _ := reply.([]byte)
+	if len(raw) == 0 {
+		return CacheEntryInfo{}, false
+	}
+	size, err := c.do("STRLEN", c.prefix+key)
+	if err != nil {
+		fmt.Printf("[Cache] Redis STRLEN failed: %v\n", err)
+		return CacheEntryInfo{}, false
+	}
+
+	header, _, err := splitCacheEntry(raw)
+	if err != nil || header.Key == "" {
+		// 旧格式或头部超出读取范围，只能从键中解析
+		header = cacheEntryHeader{Key: key}
+	}
+	n, _ := size.(int64)
+	return header.info(n, 0), true
+}
+
+// scan 使用 SCAN 遍历匹配的键，避免 KEYS 阻塞 Redis
+func (c *RedisCache) scan(match string) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := c.do("SCAN", cursor, "MATCH", match, "COUNT", strconv.Itoa(redisScanCount))
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 2 {
+			return nil, fmt.Errorf("unexpected SCAN reply: %v", reply)
+		}
+		next, _ := parts[0].([]byte)
+		items, _ := parts[1].([]interface{})
+		for _, item := range items {
+			if k, ok := item.([]byte); ok {
+				keys = append(keys, string(k))
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+// do 从连接池取连接执行一条命令，出错的连接直接丢弃
+func (c *RedisCache) do(args ...string) (interface{}, error) {
+	conn, err := c.getConn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(args...)
+	if err != nil {
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			conn.Close()
+			return nil, err
+		}
+	}
+	c.putConn(conn)
+	return reply, err
+}
+
+func (c *RedisCache) getConn() (*redisConn, error) {
+	select {
+	case conn := <-c.idle:
+		return conn, nil
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", c.addr, redisDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: netConn, r: bufio.NewReader(netConn)}
+	if c.password != "" {
+		if _, err := conn.do("AUTH", c.password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis auth failed: %v", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := conn.do("SELECT", strconv.Itoa(c.db)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis select db failed: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+func (c *RedisCache) putConn(conn *redisConn) {
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// redisError Redis 返回的错误回复（-ERR ...），连接本身仍然可用
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// redisConn 单个 RESP 连接
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (rc *redisConn) Close() error {
+	return rc.conn.Close()
+}
+
+// do 发送命令（RESP 数组）并读取一条回复
+func (rc *redisConn) do(args ...string) (interface{}, error) {
+	rc.conn.SetDeadline(time.Now().Add(redisIOTimeout))
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	if _, err := rc.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readRESP(rc.r)
+}
+
+// readRESP 解析一条 RESP 回复：
+// 简单字符串返回 string，错误返回 redisError，整数返回 int64，
+// 批量字符串返回 []byte（空值为 nil），数组返回 []interface{}
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid RESP line: %q", line)
+	}
+	body := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := readRESP(r)
+			if err != nil {
+				// 数组中的错误元素不影响后续解析
+				var redisErr redisError
+				if !errors.As(err, &redisErr) {
+					return nil, err
+				}
+				item = redisErr
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unknown RESP type: %q", line[0])
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis 进程内的最小 RESP 服务，实现 RedisCache 用到的命令
+// SCAN 每页固定返回 2 个键，以覆盖多页遍历
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu    sync.Mutex
+	data  map[string][]byte
+	conns int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	s := &fakeRedis{ln: ln, password: password, data: make(map[string][]byte)}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeRedis) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		reply, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			b, _ := item.([]byte)
+			args[i] = string(b)
+		}
+		if len(args) == 0 {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		if cmd == "AUTH" {
+			if args[1] != s.password {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+			authed = true
+		}
+		io.WriteString(conn, s.exec(cmd, args[1:]))
+	}
+}
+
+func bulk(b []byte) string {
+	if b == nil {
+		return "$-1\r\n"
+	}
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(b), b)
+}
+
+func (s *fakeRedis) exec(cmd string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		s.data[args[0]] = []byte(args[1])
+		return "+OK\r\n"
+	case "GET":
+		return bulk(s.data[args[0]])
+	case "DEL":
+		n := 0
+		for _, k := range args {
+			if _, ok := s.data[k]; ok {
+				delete(s.data, k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "STRLEN":
+		return fmt.Sprintf(":%d\r\n", len(s.data[args[0]]))
+	case "GETRANGE":
+		v, ok := s.data[args[0]]
+		if !ok {
+			return bulk([]byte{})
+		}
+		end, _ := strconv.Atoi(args[2])
+		return bulk(v[:min(end+1, len(v))])
+	case "SCAN":
+		cursor, _ := strconv.Atoi(args[0])
+		prefix := strings.TrimSuffix(args[2], "*")
+		var keys []string
+		for k := range s.data {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		end := min(cursor+2, len(keys))
+		next := end
+		if end >= len(keys) {
+			next = 0
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "*2\r\n%s*%d\r\n", bulk([]byte(strconv.Itoa(next))), end-min(cursor, end))
+		for _, k := range keys[min(cursor, end):end] {
+			sb.WriteString(bulk([]byte(k)))
+		}
+		return sb.String()
+	}
+	return "-ERR unknown command '" + cmd + "'\r\n"
+}
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    interface{}
+		wantErr bool
+	}{
+		{"+OK\r\n", "OK", false},
+		{":42\r\n", int64(42), false},
+		{"$5\r\nhello\r\n", []byte("hello"), false},
+		{"$0\r\n\r\n", []byte{}, false},
+		{"$-1\r\n", nil, false},
+		{"*-1\r\n", nil, false},
+		{"*2\r\n$1\r\na\r\n:1\r\n", []interface{}{[]byte("a"), int64(1)}, false},
+		{"*2\r\n*1\r\n+x\r\n$-1\r\n", []interface{}{[]interface{}{"x"}, nil}, false},
+		{"*2\r\n-ERR bad\r\n+ok\r\n", []interface{}{redisError("ERR bad"), "ok"}, false},
+		{"-ERR bad\r\n", nil, true},
+		{"+OK\n", nil, true},
+		{"?x\r\n", nil, true},
+		{"$5\r\nhi\r\n", nil, true},
+		{":x\r\n", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := readRESP(bufio.NewReader(strings.NewReader(tt.in)))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("readRESP(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("readRESP(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+
+	var redisErr redisError
+	if _, err := readRESP(bufio.NewReader(strings.NewReader("-WRONGTYPE x\r\n"))); !errors.As(err, &redisErr) {
+		t.Errorf("error reply should be a redisError, got %v", err)
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	c, err := NewRedisCache(server.addr(), "secret", 1, "goloc:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := &RepoStats{Branch: "main", Commit: "abc", Files: []FileStat{{Path: "main.go", Language: "Go", Code: 10}}}
+	keys := []string{
+		"https://github.com/a/one|abc|excl=1",
+		"https://github.com/a/one|ref:main|excl=1",
+		"https://github.com/a/two|def|excl=1",
+	}
+	for _, key := range keys {
+		c.Set(key, stats, 60)
+	}
+	// 其他前缀的数据不受影响
+	server.exec("SET", []string{"other:key", "x"})
+
+	got, ok := c.Get(keys[0])
+	if !ok || !reflect.DeepEqual(got.Files, stats.Files) || got.Commit != "abc" {
+		t.Fatalf("Get() = %+v, %v", got, ok)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Error("Get(missing) hit")
+	}
+	if s := c.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 1 hit and 1 miss", s)
+	}
+
+	info, ok := c.Info(keys[1])
+	if !ok || info.Key != keys[1] || info.Repo != "https://github.com/a/one" || info.Branch != "main" || info.Size == 0 {
+		t.Errorf("Info() = %+v, %v", info, ok)
+	}
+	if list := c.List(); len(list) != len(keys) {
+		t.Errorf("List() returned %d entries, want %d", len(list), len(keys))
+	}
+
+	c.Delete(keys[2])
+	if _, ok := c.Get(keys[2]); ok {
+		t.Error("Get() after Delete hit")
+	}
+
+	c.Clear()
+	if list := c.List(); len(list) != 0 {
+		t.Errorf("List() after Clear = %+v", list)
+	}
+	if server.exec("GET", []string{"other:key"}) != bulk([]byte("x")) {
+		t.Error("Clear removed keys outside the prefix")
+	}
+
+	// 顺序执行的命令复用同一个连接（PING 之后一直放回连接池）
+	server.mu.Lock()
+	conns := server.conns
+	server.mu.Unlock()
+	if conns != 1 {
+		t.Errorf("opened %d connections, want 1", conns)
+	}
+}
+
+func TestRedisCacheErrors(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	if _, err := NewRedisCache(server.addr(), "wrong", 0, "goloc:"); err == nil {
+		t.Error("NewRedisCache with a wrong password succeeded")
+	}
+
+	c, err := NewRedisCache(server.addr(), "secret", 0, "goloc:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 错误回复不关闭连接
+	if _, err := c.do("NOPE"); err == nil {
+		t.Error("unknown command succeeded")
+	}
+	if _, err := c.do("PING"); err != nil {
+		t.Errorf("PING after error reply: %v", err)
+	}
+	// 无法解码的条目按未命中处理并删除
+	server.exec("SET", []string{"goloc:bad", "{\"key\":\"bad\"}\nnot gzip"})
+	if _, ok := c.Get("bad"); ok {
+		t.Error("Get() of a corrupt entry hit")
+	}
+	if server.exec("GET", []string{"goloc:bad"}) != bulk(nil) {
+		t.Error("corrupt entry was not dropped")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cacheKeys(c *SafeCache) []string {
+	var keys []string
+	for _, info := range c.List() {
+		keys = append(keys, info.Key)
+	}
+	return keys
+}
+
+func TestSafeCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(time.Hour, CacheLimits{MaxEntries: 3})
+	stats := &RepoStats{Commit: "abc"}
+
+	c.Set("a", stats, 3600)
+	c.Set("b", stats, 3600)
+	c.Set("c", stats, 3600)
+	// 读取 a 后 b 成为最久未使用的条目
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing before eviction")
+	}
+	c.Set("d", stats, 3600)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if got, want := cacheKeys(c), []string{"d", "a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LRU order = %v, want %v", got, want)
+	}
+
+	// 覆盖已有键不淘汰其他条目，只把它移到队首
+	c.Set("c", stats, 3600)
+	if got, want := cacheKeys(c), []string{"c", "d", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("LRU order after overwrite = %v, want %v", got, want)
+	}
+
+	s := c.Stats()
+	if s.Entries != 3 || s.Evictions != 1 {
+		t.Errorf("stats = %+v, want 3 entries and 1 eviction", s)
+	}
+}
+
+func TestSafeCacheEvictsByBytes(t *testing.T) {
+	big := &RepoStats{Files: []FileStat{{Path: strings.Repeat("x", 1000)}}}
+	size := estimateRepoStatsSize(big)
+	c := NewCache(time.Hour, CacheLimits{MaxBytes: 2 * size})
+
+	c.Set("a", big, 3600)
+	c.Set("b", big, 3600)
+	c.Set("c", big, 3600)
+
+	if got, want := cacheKeys(c), []string{"c", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	if s := c.Stats(); s.Bytes != 2*size || s.Evictions != 1 {
+		t.Errorf("stats = %+v, want %d bytes and 1 eviction", s, 2*size)
+	}
+
+	c.Delete("b")
+	if s := c.Stats(); s.Bytes != size {
+		t.Errorf("bytes after delete = %d, want %d", s.Bytes, size)
+	}
+}
+
+func TestSafeCacheExpiry(t *testing.T) {
+	c := NewCache(time.Hour, CacheLimits{})
+	c.Set("fresh", &RepoStats{}, 3600)
+	c.Set("stale", &RepoStats{}, -1)
+
+	if _, ok := c.Get("stale"); ok {
+		t.Error("expired entry returned")
+	}
+	if _, ok := c.Get("fresh"); !ok {
+		t.Error("fresh entry missing")
+	}
+	s := c.Stats()
+	if s.Entries != 1 || s.Expired != 1 || s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestSafeCacheCompressesLargeEntries(t *testing.T) {
+	c := NewCache(time.Hour, CacheLimits{CompressThreshold: 1})
+	want := &RepoStats{Commit: "abc", Files: []FileStat{{Path: "main.go", Language: "Go", Code: 10}}}
+	c.Set("k", want, 3600)
+
+	got, ok := c.Get("k")
+	if !ok {
+		t.Fatal("compressed entry missing")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decompressed = %+v, want %+v", got, want)
+	}
+}
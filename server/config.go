@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -45,14 +47,42 @@ var DefaultExcludeDirs = []string{
 }
 
 type Config struct {
-	CacheTTL             int64    `json:"cache_ttl_seconds"`
-	DefaultDepth         int      `json:"default_depth"`
-	RequestTimeout       int      `json:"request_timeout_seconds"`
-	MaxRepoSizeMB        int64    `json:"max_repo_size_mb"`
-	ExcludeDirs          []string `json:"exclude_dirs"`
-	IncludeDataFiles     bool     `json:"include_data_files"`    // 是否统计数据文件（JSON/XML/YAML等）
-	IncludeDocumentation bool     `json:"include_documentation"` // 是否统计文档文件（Markdown/TXT等）
-	GithubToken          string   `json:"-"`
+	CacheTTL                 int64    `json:"cache_ttl_seconds"`
+	CacheStaleTTL            int64    `json:"cache_stale_ttl_seconds"`     // 缓存过期后仍可先返回旧结果（并后台刷新）的时长，0 表示不返回过期结果
+	CacheBackend             string   `json:"cache_backend"`               // 缓存后端：memory / disk / redis，仅启动时生效
+	CacheDir                 string   `json:"cache_dir"`                   // disk 后端的数据目录，仅启动时生效
+	CacheMaxMemoryMB         int64    `json:"cache_max_memory_mb"`         // memory 后端的估算内存上限，0 表示不限制，仅启动时生效
+	CacheMaxEntries          int      `json:"cache_max_entries"`           // memory 后端的最大条目数，0 表示不限制，仅启动时生效
+	CacheCompressThresholdKB int64    `json:"cache_compress_threshold_kb"` // memory 后端中超过该大小的条目压缩存储，0 表示不压缩
+	RedisAddr                string   `json:"redis_addr"`                  // redis 后端地址，如 localhost:6379，仅启动时生效
+	RedisDB                  int      `json:"redis_db"`
+	RedisKeyPrefix           string   `json:"redis_key_prefix"`
+	RedisPassword            string   `json:"-"`
+	DefaultDepth             int      `json:"default_depth"`
+	RequestTimeout           int      `json:"request_timeout_seconds"`
+	MaxConcurrentAnalyses    int      `json:"max_concurrent_analyses"` // 同时进行的克隆/统计数量上限，仅启动时生效
+	MaxBatchAnalyses         int      `json:"max_batch_analyses"`      // 其中批量分析的上限，0 表示只受总数限制，仅启动时生效
+	MaxBackgroundAnalyses    int      `json:"max_background_analyses"` // 其中后台刷新的上限，0 表示只受总数限制，仅启动时生效
+	AnalysisQueueSize        int      `json:"analysis_queue_size"`     // 每个优先级等待执行的分析数量上限，超出时返回 503，仅启动时生效
+	MaxRepoSizeMB            int64    `json:"max_repo_size_mb"`
+	ExcludeDirs              []string `json:"exclude_dirs"`
+	IncludePatterns          []string `json:"include_patterns"`      // gitignore 风格的包含规则，为空时包含所有文件
+	ExcludePatterns          []string `json:"exclude_patterns"`      // gitignore 风格的排除规则，如 docs/generated/**、**/*.min.js
+	IncludeDataFiles         bool     `json:"include_data_files"`    // 是否统计数据文件（JSON/XML/YAML等）
+	IncludeDocumentation     bool     `json:"include_documentation"` // 是否统计文档文件（Markdown/TXT等）
+	IncludeMarkup            bool     `json:"include_markup"`        // 是否统计标记/模板文件（HTML等）
+	IncludeStyle             bool     `json:"include_style"`         // 是否统计样式文件（CSS/Sass等）
+	IncludeBuild             bool     `json:"include_build"`         // 是否统计构建脚本（Makefile/CMake等）
+	IncludeTests             bool     `json:"include_tests"`         // 是否统计测试代码
+	IncludeLockfiles         bool     `json:"include_lockfiles"`     // 是否统计依赖锁文件（package-lock.json/go.sum等）
+
+	LanguageCategories map[string]LanguageCategory `json:"language_categories"` // 语言分类覆盖表，如 {"HTML": "programming"}
+	TestPatterns       []string                    `json:"test_patterns"`       // 测试文件路径规则
+	UseRepoConfig      bool                        `json:"use_repo_config"`     // 是否读取仓库根目录的 .goloc.yml / .golocignore
+
+	GithubToken string `json:"-"`
+	GitlabToken string `json:"-"` // 列出 gitlab.com 群组项目时使用，不发送给其他 GitLab 实例
+	AdminToken  string `json:"-"` // 缓存管理中 DELETE 接口的令牌，未配置时禁用这些接口
 }
 
 type AppConfig struct {
@@ -62,14 +92,36 @@ type AppConfig struct {
 
 func NewAppConfig() *AppConfig {
 	defaultCfg := Config{
-		CacheTTL:             60 * 60 * 24 * 7,
-		DefaultDepth:         5,
-		RequestTimeout:       120,
-		MaxRepoSizeMB:        100,
-		ExcludeDirs:          DefaultExcludeDirs,
-		IncludeDataFiles:     false, // 默认不统计数据文件
-		IncludeDocumentation: false, // 默认不统计文档文件
-		GithubToken:          "",
+		CacheTTL:                 60 * 60 * 24 * 7,
+		CacheStaleTTL:            60 * 60 * 24 * 7,
+		CacheBackend:             CacheBackendMemory,
+		CacheDir:                 filepath.Join(os.TempDir(), "goloc_cache"),
+		CacheMaxMemoryMB:         512,
+		CacheMaxEntries:          1000,
+		CacheCompressThresholdKB: 256,
+		RedisAddr:                "localhost:6379",
+		RedisKeyPrefix:           "goloc:",
+		DefaultDepth:             5,
+		RequestTimeout:           120,
+		MaxConcurrentAnalyses:    4,
+		MaxBatchAnalyses:         2,
+		MaxBackgroundAnalyses:    1,
+		AnalysisQueueSize:        50,
+		MaxRepoSizeMB:            100,
+		ExcludeDirs:              DefaultExcludeDirs,
+		IncludePatterns:          []string{},
+		ExcludePatterns:          []string{},
+		IncludeDataFiles:         false, // 默认不统计数据文件
+		IncludeDocumentation:     false, // 默认不统计文档文件
+		IncludeMarkup:            true,
+		IncludeStyle:             true,
+		IncludeBuild:             true,
+		IncludeTests:             true,
+		IncludeLockfiles:         false, // 默认不统计锁文件
+		LanguageCategories:       map[string]LanguageCategory{},
+		TestPatterns:             DefaultTestPatterns,
+		UseRepoConfig:            true,
+		GithubToken:              "",
 	}
 
 	if val := os.Getenv("CACHE_TTL"); val != "" {
@@ -77,14 +129,80 @@ func NewAppConfig() *AppConfig {
 			defaultCfg.CacheTTL = i
 		}
 	}
+	if val := os.Getenv("CACHE_STALE_TTL"); val != "" {
+		if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+			defaultCfg.CacheStaleTTL = i
+		}
+	}
+	if val := os.Getenv("CACHE_BACKEND"); val != "" {
+		defaultCfg.CacheBackend = strings.ToLower(strings.TrimSpace(val))
+	}
+	if val := os.Getenv("CACHE_DIR"); val != "" {
+		defaultCfg.CacheDir = val
+	}
+	if val := os.Getenv("CACHE_MAX_MEMORY_MB"); val != "" {
+		if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+			defaultCfg.CacheMaxMemoryMB = i
+		}
+	}
+	if val := os.Getenv("CACHE_MAX_ENTRIES"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			defaultCfg.CacheMaxEntries = i
+		}
+	}
+	if val := os.Getenv("CACHE_COMPRESS_THRESHOLD_KB"); val != "" {
+		if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+			defaultCfg.CacheCompressThresholdKB = i
+		}
+	}
+	if val := os.Getenv("REDIS_ADDR"); val != "" {
+		defaultCfg.RedisAddr = val
+	}
+	if val := os.Getenv("REDIS_PASSWORD"); val != "" {
+		defaultCfg.RedisPassword = val
+	}
+	if val := os.Getenv("REDIS_DB"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			defaultCfg.RedisDB = i
+		}
+	}
+	if val := os.Getenv("REDIS_KEY_PREFIX"); val != "" {
+		defaultCfg.RedisKeyPrefix = val
+	}
 	if val := os.Getenv("MAX_REPO_SIZE_MB"); val != "" {
 		if i, err := strconv.ParseInt(val, 10, 64); err == nil {
 			defaultCfg.MaxRepoSizeMB = i
 		}
 	}
+	if val := os.Getenv("MAX_CONCURRENT_ANALYSES"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			defaultCfg.MaxConcurrentAnalyses = i
+		}
+	}
+	if val := os.Getenv("MAX_BATCH_ANALYSES"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			defaultCfg.MaxBatchAnalyses = i
+		}
+	}
+	if val := os.Getenv("MAX_BACKGROUND_ANALYSES"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			defaultCfg.MaxBackgroundAnalyses = i
+		}
+	}
+	if val := os.Getenv("ANALYSIS_QUEUE_SIZE"); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			defaultCfg.AnalysisQueueSize = i
+		}
+	}
 	if val := os.Getenv("GITHUB_TOKEN"); val != "" {
 		defaultCfg.GithubToken = val
 	}
+	if val := os.Getenv("GITLAB_TOKEN"); val != "" {
+		defaultCfg.GitlabToken = val
+	}
+	if val := os.Getenv("ADMIN_TOKEN"); val != "" {
+		defaultCfg.AdminToken = val
+	}
 	// 从环境变量读取额外的排除目录（逗号分隔）
 	if val := os.Getenv("EXCLUDE_DIRS"); val != "" {
 		extraDirs := strings.Split(val, ",")
@@ -95,62 +213,238 @@ func NewAppConfig() *AppConfig {
 			}
 		}
 	}
+	// 从环境变量读取路径排除规则（逗号分隔）
+	if val := os.Getenv("EXCLUDE_PATTERNS"); val != "" {
+		for _, pattern := range strings.Split(val, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern != "" {
+				defaultCfg.ExcludePatterns = append(defaultCfg.ExcludePatterns, pattern)
+			}
+		}
+	}
 
 	return &AppConfig{
 		inner: defaultCfg,
 	}
 }
 
+// FilterOptions 单次分析请求的过滤选项，未设置的字段沿用全局配置，只对本次请求生效
+type FilterOptions struct {
+	ExcludeDirs          []string                    `json:"exclude_dirs"`
+	IncludePatterns      []string                    `json:"include_patterns"`
+	ExcludePatterns      []string                    `json:"exclude_patterns"`
+	IncludeDataFiles     *bool                       `json:"include_data_files"`
+	IncludeDocumentation *bool                       `json:"include_documentation"`
+	IncludeMarkup        *bool                       `json:"include_markup"`
+	IncludeStyle         *bool                       `json:"include_style"`
+	IncludeBuild         *bool                       `json:"include_build"`
+	IncludeTests         *bool                       `json:"include_tests"`
+	IncludeLockfiles     *bool                       `json:"include_lockfiles"`
+	LanguageCategories   map[string]LanguageCategory `json:"language_categories"` // 与全局分类覆盖表合并
+	TestPatterns         []string                    `json:"test_patterns"`
+	UseRepoConfig        *bool                       `json:"use_repo_config"`
+}
+
+// WithOverrides 在当前配置的副本上应用请求级过滤选项
+func (c Config) WithOverrides(o FilterOptions) Config {
+	merged := c
+	if o.ExcludeDirs != nil {
+		merged.ExcludeDirs = o.ExcludeDirs
+	}
+	if o.IncludePatterns != nil {
+		merged.IncludePatterns = o.IncludePatterns
+	}
+	if o.ExcludePatterns != nil {
+		merged.ExcludePatterns = o.ExcludePatterns
+	}
+	if o.TestPatterns != nil {
+		merged.TestPatterns = o.TestPatterns
+	}
+
+	toggles := []struct {
+		override *bool
+		target   *bool
+	}{
+		{o.IncludeDataFiles, &merged.IncludeDataFiles},
+		{o.IncludeDocumentation, &merged.IncludeDocumentation},
+		{o.IncludeMarkup, &merged.IncludeMarkup},
+		{o.IncludeStyle, &merged.IncludeStyle},
+		{o.IncludeBuild, &merged.IncludeBuild},
+		{o.IncludeTests, &merged.IncludeTests},
+		{o.IncludeLockfiles, &merged.IncludeLockfiles},
+		{o.UseRepoConfig, &merged.UseRepoConfig},
+	}
+	for _, t := range toggles {
+		if t.override != nil {
+			*t.target = *t.override
+		}
+	}
+
+	if len(o.LanguageCategories) > 0 {
+		merged.LanguageCategories = make(map[string]LanguageCategory, len(c.LanguageCategories)+len(o.LanguageCategories))
+		for lang, category := range c.LanguageCategories {
+			merged.LanguageCategories[lang] = category
+		}
+		for lang, category := range o.LanguageCategories {
+			merged.LanguageCategories[lang] = category
+		}
+	}
+	return merged
+}
+
+// ParseFilterQuery 从查询参数中读取请求级过滤选项，字段名与 FilterOptions 的 JSON 字段相同
+// 列表可重复或用逗号分隔，参数存在但为空时表示空列表；language_categories 的格式为 语言:分类
+// 用于 GET 接口按分析时的过滤选项读取同一份结果
+func ParseFilterQuery(values url.Values) (FilterOptions, error) {
+	var o FilterOptions
+	lists := []struct {
+		name   string
+		target *[]string
+	}{
+		{"exclude_dirs", &o.ExcludeDirs},
+		{"include_patterns", &o.IncludePatterns},
+		{"exclude_patterns", &o.ExcludePatterns},
+		{"test_patterns", &o.TestPatterns},
+	}
+	for _, l := range lists {
+		if _, ok := values[l.name]; ok {
+			*l.target = append([]string{}, splitQueryList(values[l.name])...)
+		}
+	}
+
+	toggles := []struct {
+		name   string
+		target **bool
+	}{
+		{"include_data_files", &o.IncludeDataFiles},
+		{"include_documentation", &o.IncludeDocumentation},
+		{"include_markup", &o.IncludeMarkup},
+		{"include_style", &o.IncludeStyle},
+		{"include_build", &o.IncludeBuild},
+		{"include_tests", &o.IncludeTests},
+		{"include_lockfiles", &o.IncludeLockfiles},
+		{"use_repo_config", &o.UseRepoConfig},
+	}
+	for _, t := range toggles {
+		val := values.Get(t.name)
+		if val == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return o, fmt.Errorf("%s must be true or false", t.name)
+		}
+		*t.target = &b
+	}
+
+	for _, item := range splitQueryList(values["language_categories"]) {
+		lang, category, ok := strings.Cut(item, ":")
+		if !ok || strings.TrimSpace(lang) == "" {
+			return o, fmt.Errorf("language_categories must be language:category pairs")
+		}
+		if o.LanguageCategories == nil {
+			o.LanguageCategories = make(map[string]LanguageCategory)
+		}
+		o.LanguageCategories[strings.TrimSpace(lang)] = LanguageCategory(strings.TrimSpace(category))
+	}
+	return o, nil
+}
+
+// ValidateConfig 校验配置中的枚举值和取值范围
+func ValidateConfig(cfg Config) error {
+	if cfg.CacheStaleTTL < 0 {
+		return fmt.Errorf("cache_stale_ttl_seconds must not be negative")
+	}
+	for lang, category := range cfg.LanguageCategories {
+		if !IsValidCategory(category) {
+			return fmt.Errorf("invalid category %q for language %q", category, lang)
+		}
+	}
+	if _, err := NewPathFilter(cfg.IncludePatterns, cfg.ExcludePatterns); err != nil {
+		return err
+	}
+	if _, err := NewPathMatcher(cfg.TestPatterns); err != nil {
+		return fmt.Errorf("test pattern: %v", err)
+	}
+	return nil
+}
+
+// PoolLimits 分析槽位的限制
+func (c Config) PoolLimits() PoolLimits {
+	return PoolLimits{
+		Workers:   c.MaxConcurrentAnalyses,
+		QueueSize: c.AnalysisQueueSize,
+		ClassLimits: map[Priority]int{
+			PriorityBatch:      c.MaxBatchAnalyses,
+			PriorityBackground: c.MaxBackgroundAnalyses,
+		},
+	}
+}
+
 func (c *AppConfig) Get() Config {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.inner
 }
 
-func (c *AppConfig) Update(newCfg Config) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// ConfigUpdate POST /api/config 的请求体，只更新请求中出现的字段
+type ConfigUpdate struct {
+	MaxRepoSizeMB  int64  `json:"max_repo_size_mb"`
+	CacheTTL       int64  `json:"cache_ttl_seconds"`
+	CacheStaleTTL  *int64 `json:"cache_stale_ttl_seconds"` // nil（未传）时保持不变，传 0 关闭陈旧结果
+	DefaultDepth   int    `json:"default_depth"`
+	RequestTimeout int    `json:"request_timeout_seconds"`
+	// 过滤选项：开关为 nil（未传）时保持不变；列表传入即整体替换（允许传空数组来清空）
+	// 与请求级覆盖不同，language_categories 传入时整体替换全局分类覆盖表
+	FilterOptions
+}
 
-	// 检查排除目录是否有变化
-	excludeDirsChanged := false
-	if newCfg.ExcludeDirs != nil {
-		if len(newCfg.ExcludeDirs) != len(c.inner.ExcludeDirs) {
-			excludeDirsChanged = true
-		} else {
-			for i, dir := range newCfg.ExcludeDirs {
-				if dir != c.inner.ExcludeDirs[i] {
-					excludeDirsChanged = true
-					break
-				}
-			}
-		}
+// WithUpdate 在当前配置的副本上应用更新
+func (c Config) WithUpdate(u ConfigUpdate) Config {
+	// 缓存键包含排除目录集合的哈希，旧配置下的缓存仍可供使用相同排除规则的请求复用，更新 exclude_dirs 无需清空
+	merged := c.WithOverrides(u.FilterOptions)
+	if u.LanguageCategories != nil {
+		merged.LanguageCategories = u.LanguageCategories
 	}
-
-	if newCfg.MaxRepoSizeMB > 0 {
-		c.inner.MaxRepoSizeMB = newCfg.MaxRepoSizeMB
+	if u.MaxRepoSizeMB > 0 {
+		merged.MaxRepoSizeMB = u.MaxRepoSizeMB
 	}
-	if newCfg.CacheTTL > 0 {
-		c.inner.CacheTTL = newCfg.CacheTTL
+	if u.CacheTTL > 0 {
+		merged.CacheTTL = u.CacheTTL
 	}
-	if newCfg.DefaultDepth > 0 {
-		c.inner.DefaultDepth = newCfg.DefaultDepth
+	if u.CacheStaleTTL != nil {
+		merged.CacheStaleTTL = *u.CacheStaleTTL
 	}
-	if newCfg.RequestTimeout > 0 {
-		c.inner.RequestTimeout = newCfg.RequestTimeout
+	if u.DefaultDepth > 0 {
+		merged.DefaultDepth = u.DefaultDepth
 	}
-	// 如果传入了 ExcludeDirs，则更新（允许传空数组来清空）
-	if newCfg.ExcludeDirs != nil {
-		c.inner.ExcludeDirs = newCfg.ExcludeDirs
+	if u.RequestTimeout > 0 {
+		merged.RequestTimeout = u.RequestTimeout
 	}
-	// 更新语言过滤选项（这些是 bool，可以直接覆盖）
-	c.inner.IncludeDataFiles = newCfg.IncludeDataFiles
-	c.inner.IncludeDocumentation = newCfg.IncludeDocumentation
+	return merged
+}
 
-	// 如果排除目录有变化，清空缓存
-	if excludeDirsChanged && cache != nil {
-		cache.Clear()
-		fmt.Println("[Config] ExcludeDirs changed, cache cleared")
-	}
+func (c *AppConfig) Update(u ConfigUpdate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	fmt.Printf("[Config] Updated: %+v\n", c.inner)
+	c.inner = c.inner.WithUpdate(u)
+	fmt.Printf("[Config] Updated: %+v\n", c.inner.redacted())
+}
+
+// redacted 返回隐去密钥的副本，用于日志输出
+func (c Config) redacted() Config {
+	if c.GithubToken != "" {
+		c.GithubToken = "***"
+	}
+	if c.GitlabToken != "" {
+		c.GitlabToken = "***"
+	}
+	if c.RedisPassword != "" {
+		c.RedisPassword = "***"
+	}
+	if c.AdminToken != "" {
+		c.AdminToken = "***"
+	}
+	return c
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigRedacted(t *testing.T) {
+	cfg := Config{GithubToken: "ghp_secret", GitlabToken: "glpat_secret", RedisPassword: "hunter2", AdminToken: "adm1n"}
+	out := fmt.Sprintf("%+v", cfg.redacted())
+	if strings.Contains(out, "ghp_secret") || strings.Contains(out, "glpat_secret") || strings.Contains(out, "hunter2") || strings.Contains(out, "adm1n") {
+		t.Errorf("redacted config leaks secrets: %s", out)
+	}
+	if cfg.GithubToken != "ghp_secret" {
+		t.Error("redacted() modified the original config")
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestConfigWithUpdate(t *testing.T) {
+	base := NewAppConfig().Get()
+
+	// 只传一个字段时其他开关保持不变
+	cfg := base.WithUpdate(ConfigUpdate{DefaultDepth: 3})
+	if cfg.DefaultDepth != 3 {
+		t.Errorf("DefaultDepth = %d, want 3", cfg.DefaultDepth)
+	}
+	if !cfg.IncludeMarkup || !cfg.IncludeStyle || !cfg.IncludeBuild || !cfg.IncludeTests || !cfg.UseRepoConfig {
+		t.Errorf("partial update turned default-true toggles off: %+v", cfg)
+	}
+	if cfg.IncludeLockfiles != base.IncludeLockfiles || len(cfg.ExcludeDirs) != len(base.ExcludeDirs) {
+		t.Errorf("partial update changed unrelated fields: %+v", cfg)
+	}
+
+	// 显式传入的开关和列表生效，空数组清空
+	cfg = base.WithUpdate(ConfigUpdate{FilterOptions: FilterOptions{
+		IncludeTests:     boolPtr(false),
+		IncludeLockfiles: boolPtr(true),
+		ExcludeDirs:      []string{},
+	}})
+	if cfg.IncludeTests || !cfg.IncludeLockfiles || len(cfg.ExcludeDirs) != 0 || !cfg.IncludeMarkup {
+		t.Errorf("explicit update not applied: %+v", cfg)
+	}
+
+	// 陈旧期未传时保持不变，可显式设为 0 关闭
+	if cfg := base.WithUpdate(ConfigUpdate{}); cfg.CacheStaleTTL != base.CacheStaleTTL {
+		t.Errorf("CacheStaleTTL = %d after empty update, want %d", cfg.CacheStaleTTL, base.CacheStaleTTL)
+	}
+	var update ConfigUpdate
+	if err := json.Unmarshal([]byte(`{"cache_stale_ttl_seconds": 0}`), &update); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := base.WithUpdate(update); cfg.CacheStaleTTL != 0 {
+		t.Errorf("CacheStaleTTL = %d, want 0", cfg.CacheStaleTTL)
+	}
+	negative := int64(-1)
+	if err := ValidateConfig(base.WithUpdate(ConfigUpdate{CacheStaleTTL: &negative})); err == nil {
+		t.Error("negative cache_stale_ttl_seconds accepted")
+	}
+
+	// language_categories 整体替换
+	base.LanguageCategories = map[string]LanguageCategory{"HTML": CategoryProgramming}
+	cfg = base.WithUpdate(ConfigUpdate{FilterOptions: FilterOptions{LanguageCategories: map[string]LanguageCategory{"CSS": CategoryProgramming}}})
+	if _, ok := cfg.LanguageCategories["HTML"]; ok || len(cfg.LanguageCategories) != 1 {
+		t.Errorf("LanguageCategories = %v, want only CSS", cfg.LanguageCategories)
+	}
+}
+
+func TestParseFilterQuery(t *testing.T) {
+	values, _ := url.ParseQuery("exclude_dirs=node_modules,vendor&exclude_dirs=dist&include_patterns=&include_tests=false&use_repo_config=1&language_categories=HTML:programming,CSS:style")
+	o, err := ParseFilterQuery(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(o.ExcludeDirs, []string{"node_modules", "vendor", "dist"}) {
+		t.Errorf("ExcludeDirs = %v", o.ExcludeDirs)
+	}
+	if o.IncludePatterns == nil || len(o.IncludePatterns) != 0 {
+		t.Errorf("IncludePatterns = %#v, want empty list", o.IncludePatterns)
+	}
+	if o.ExcludePatterns != nil {
+		t.Errorf("ExcludePatterns = %#v, want nil", o.ExcludePatterns)
+	}
+	if o.IncludeTests == nil || *o.IncludeTests || o.UseRepoConfig == nil || !*o.UseRepoConfig || o.IncludeMarkup != nil {
+		t.Errorf("toggles = tests %v, repo config %v, markup %v", o.IncludeTests, o.UseRepoConfig, o.IncludeMarkup)
+	}
+	want := map[string]LanguageCategory{"HTML": CategoryProgramming, "CSS": "style"}
+	if !reflect.DeepEqual(o.LanguageCategories, want) {
+		t.Errorf("LanguageCategories = %v", o.LanguageCategories)
+	}
+
+	for _, query := range []string{"include_tests=maybe", "language_categories=HTML"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := ParseFilterQuery(values); err == nil {
+			t.Errorf("ParseFilterQuery(%q) succeeded, want error", query)
+		}
+	}
+}
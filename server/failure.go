@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorClass 分析失败的类别，决定返回的错误码和失败结果的缓存时长
+type ErrorClass string
+
+const (
+	ErrorNotFound     ErrorClass = "not_found"     // 仓库或分支不存在（私有仓库在未授权时同样表现为不存在）
+	ErrorAccessDenied ErrorClass = "access_denied" // 需要认证或无权访问
+	ErrorRateLimited  ErrorClass = "rate_limited"  // GitHub API 限流
+	ErrorTooLarge     ErrorClass = "too_large"     // 仓库超过大小限制
+	ErrorCloneFailed  ErrorClass = "clone_failed"  // 克隆失败（其他原因）
+	ErrorTimeout      ErrorClass = "timeout"       // 分析超时
+	ErrorBusy         ErrorClass = "busy"          // 分析队列已满
+	ErrorCanceled     ErrorClass = "canceled"      // 客户端断开或任务被取消
+	ErrorUpstream     ErrorClass = "upstream"      // GitHub API 网络错误或非预期的响应
+	ErrorInternal     ErrorClass = "internal"      // 统计等服务端内部错误
+)
+
+// failureTTL 各类失败的缓存时长，未列出的类别（限流、超时、网络错误等）属于暂时性错误，不缓存
+// too_large 按提交缓存，见 rememberFailure
+var failureTTL = map[ErrorClass]time.Duration{
+	ErrorNotFound:     10 * time.Minute,
+	ErrorAccessDenied: 10 * time.Minute,
+	ErrorCloneFailed:  time.Minute,
+	ErrorTooLarge:     time.Hour,
+}
+
+// AnalysisError 带类别的分析错误
+type AnalysisError struct {
+	Class      ErrorClass `json:"class"`
+	Message    string     `json:"message"`
+	SizeMB     int64      `json:"size_mb,omitempty"`  // too_large 时的仓库大小
+	LimitMB    int64      `json:"limit_mb,omitempty"` // too_large 时的大小限制
+	Cached     bool       `json:"cached"`             // 是否为缓存的失败结果
+	RetryAfter int64      `json:"retry_after_seconds,omitempty"`
+}
+
+func (e *AnalysisError) Error() string {
+	return e.Message
+}
+
+// Code 对应的响应码
+func (e *AnalysisError) Code() int {
+	switch e.Class {
+	case ErrorNotFound:
+		return 404
+	case ErrorAccessDenied:
+		return 403
+	case ErrorTooLarge:
+		return 413
+	case ErrorRateLimited:
+		return 429
+	case ErrorBusy:
+		return 503
+	case ErrorCanceled:
+		return 499
+	case ErrorTimeout:
+		return 504
+	case ErrorUpstream:
+		return 502
+	default:
+		return 500
+	}
+}
+
+// errCanceled 分析被取消
+var errCanceled = &AnalysisError{Class: ErrorCanceled, Message: "analysis canceled"}
+
+func newAnalysisError(class ErrorClass, format string, args ...interface{}) *AnalysisError {
+	return &AnalysisError{Class: class, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorClass 返回错误的类别，未分类的错误视为内部错误
+func errorClass(err error) ErrorClass {
+	var analysisErr *AnalysisError
+	if errors.As(err, &analysisErr) {
+		return analysisErr.Class
+	}
+	return ErrorInternal
+}
+
+// asAnalysisError 转换为带类别的错误，未分类的错误归为内部错误
+func asAnalysisError(err error) *AnalysisError {
+	var analysisErr *AnalysisError
+	if errors.As(err, &analysisErr) {
+		return analysisErr
+	}
+	return &AnalysisError{Class: ErrorInternal, Message: err.Error()}
+}
+
+// classifyCloneError 根据 git 的输出判断克隆失败的原因
+func classifyCloneError(ctx context.Context, err error, output string) *AnalysisError {
+	msg := fmt.Sprintf("git clone failed: %s, output: %s", err, output)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return newAnalysisError(ErrorTimeout, "%s", msg)
+	}
+	lower := strings.ToLower(output)
+	switch {
+	case strings.Contains(lower, "repository not found"),
+		strings.Contains(lower, "not found in upstream"),
+		strings.Contains(lower, "does not appear to be a git repository"):
+		return newAnalysisError(ErrorNotFound, "%s", msg)
+	case strings.Contains(lower, "authentication failed"),
+		strings.Contains(lower, "could not read username"),
+		strings.Contains(lower, "terminal prompts disabled"),
+		strings.Contains(lower, "permission denied"):
+		return newAnalysisError(ErrorAccessDenied, "%s", msg)
+	default:
+		return newAnalysisError(ErrorCloneFailed, "%s", msg)
+	}
+}
+
+// failureEntry 缓存的失败结果
+type failureEntry struct {
+	err        *AnalysisError
+	expiration int64
+}
+
+// FailureCache 失败结果的短期缓存，避免不存在、私有或超大的仓库反复请求 GitHub API 和 git
+// 只保存在内存中，各副本独立
+type FailureCache struct {
+	entries map[string]failureEntry
+	mu      sync.Mutex
+}
+
+func NewFailureCache(cleanInterval time.Duration) *FailureCache {
+	c := &FailureCache{entries: make(map[string]failureEntry)}
+	go c.startCleaner(cleanInterval)
+	return c
+}
+
+func (c *FailureCache) startCleaner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.cleanup()
+	}
+}
+
+func (c *FailureCache) cleanup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	for k, v := range c.entries {
+		if now > v.expiration {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Get 返回缓存的失败结果（副本，带剩余的重试等待时间）
+func (c *FailureCache) Get(key string) (*AnalysisError, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+	remaining := time.Duration(entry.expiration - time.Now().UnixNano())
+	if remaining <= 0 {
+		delete(c.entries, key)
+		return nil, false
+	}
+	cached := *entry.err
+	cached.Cached = true
+	cached.RetryAfter = int64(remaining.Seconds()) + 1
+	return &cached, true
+}
+
+func (c *FailureCache) Set(key string, err *AnalysisError, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = failureEntry{err: err, expiration: time.Now().Add(ttl).UnixNano()}
+}
+
+// Delete 删除指定的失败结果
+func (c *FailureCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Clear 清空所有失败结果
+func (c *FailureCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]failureEntry)
+}
+
+// PurgeRepo 删除某个仓库的所有失败结果
+func (c *FailureCache) PurgeRepo(repoURL string) {
+	prefix := NormalizeRepoURL(repoURL) + "|"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// failureKey 失败结果的缓存键，与分析选项无关
+func failureKey(repoURL string, revision string) string {
+	return NormalizeRepoURL(repoURL) + "|" + revision
+}
+
+// checkFailure 查找缓存的失败结果，revision 为 ref:分支名 或提交 SHA
+// 超大仓库的失败结果在大小限制调高后失效
+func checkFailure(repoURL string, revision string, cfg Config) (*AnalysisError, bool) {
+	key := failureKey(repoURL, revision)
+	cached, found := failures.Get(key)
+	if !found {
+		return nil, false
+	}
+	if cached.Class == ErrorTooLarge && cached.SizeMB <= cfg.MaxRepoSizeMB {
+		failures.Delete(key)
+		return nil, false
+	}
+	return cached, true
+}
+
+// rememberFailure 按错误类别缓存失败结果
+// 仓库/分支不存在等按分支缓存固定时长；超大仓库按提交缓存，分支有新提交（大小可能变化）后自然失效，
+// 同一提交最多缓存 failureTTL 中的时长（仓库大小来自 GitHub API，同一提交也可能变化）
+func rememberFailure(repoURL string, branch string, revision string, err error) {
+	var analysisErr *AnalysisError
+	if !errors.As(err, &analysisErr) {
+		return
+	}
+
+	if analysisErr.Class == ErrorTooLarge {
+		// 未能解析到提交时无法判断大小是否变化，只缓存较短时间
+		ttl := 10 * time.Minute
+		if revision != branchRevision(branch) {
+			ttl = failureTTL[ErrorTooLarge]
+		}
+		failures.Set(failureKey(repoURL, revision), analysisErr, ttl)
+		return
+	}
+	if ttl, ok := failureTTL[analysisErr.Class]; ok {
+		failures.Set(failureKey(repoURL, branchRevision(branch)), analysisErr, ttl)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRememberFailureTTL(t *testing.T) {
+	saved := failures
+	defer func() { failures = saved }()
+	failures = NewFailureCache(time.Hour)
+
+	repo := "https://github.com/a/big"
+	tooLarge := newAnalysisError(ErrorTooLarge, "repo too large")
+	tooLarge.SizeMB = 500
+
+	tests := []struct {
+		name     string
+		revision string
+		err      *AnalysisError
+		key      string
+		want     time.Duration
+	}{
+		{"too_large by commit", "0123abcd", tooLarge, failureKey(repo, "0123abcd"), failureTTL[ErrorTooLarge]},
+		{"too_large without commit", branchRevision("main"), tooLarge, failureKey(repo, branchRevision("main")), 10 * time.Minute},
+		{"not_found", "0123abcd", newAnalysisError(ErrorNotFound, "missing"), failureKey(repo, branchRevision("main")), failureTTL[ErrorNotFound]},
+	}
+	for _, tt := range tests {
+		failures.Clear()
+		rememberFailure(repo, "main", tt.revision, tt.err)
+		failures.mu.Lock()
+		entry, ok := failures.entries[tt.key]
+		failures.mu.Unlock()
+		if !ok {
+			t.Errorf("%s: no failure cached under %q", tt.name, tt.key)
+			continue
+		}
+		ttl := time.Until(time.Unix(0, entry.expiration))
+		if ttl > tt.want || ttl < tt.want-time.Minute {
+			t.Errorf("%s: cached for %v, want %v", tt.name, ttl, tt.want)
+		}
+	}
+
+	// 暂时性错误不缓存
+	failures.Clear()
+	rememberFailure(repo, "main", "0123abcd", newAnalysisError(ErrorTimeout, "timeout"))
+	if len(failures.entries) != 0 {
+		t.Errorf("transient failure cached: %v", failures.entries)
+	}
+}
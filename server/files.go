@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultFileListLimit = 50
+	maxFileListLimit     = 1000
+)
+
+// fileMetrics 可用于过滤和排序的指标
+// comment_density 为注释行占代码与注释行之和的比例（0~1），用于查找注释多于代码的文件
+var fileMetrics = []string{"lines", "code", "comments", "blanks", "comment_density"}
+
+// FileEntry 文件列表中的一项
+type FileEntry struct {
+	FileStat
+	Lines          int              `json:"lines"`
+	CommentDensity float64          `json:"comment_density"`
+	Category       LanguageCategory `json:"category"`
+}
+
+func (e FileEntry) metric(name string) float64 {
+	switch name {
+	case "lines":
+		return float64(e.Lines)
+	case "code":
+		return float64(e.Code)
+	case "comments":
+		return float64(e.Comments)
+	case "blanks":
+		return float64(e.Blanks)
+	case "comment_density":
+		return e.CommentDensity
+	}
+	return 0
+}
+
+// FileQuery 文件列表的查询条件
+type FileQuery struct {
+	Languages  map[string]bool           // 语言（不区分大小写），为空时不限
+	Categories map[LanguageCategory]bool // 分类，为空时按配置的 include_* 过滤
+	Glob       *PathMatcher              // gitignore 风格的路径规则，为空时不限
+	Min        map[string]float64        // 各指标的下限（含）
+	Max        map[string]float64        // 各指标的上限（含）
+	Sort       string                    // path 或 fileMetrics 中的指标
+	Desc       bool
+	Limit      int
+	Cursor     fileCursor
+}
+
+// ParseFileQuery 解析查询参数：language、category、glob 可重复或用逗号分隔；
+// min_<指标>、max_<指标> 过滤；sort 为 path 或指标名，order 为 asc/desc（指标默认降序，path 默认升序）；
+// limit 为每页数量，cursor 为上一页返回的 next_cursor
+func ParseFileQuery(values url.Values) (*FileQuery, error) {
+	q := &FileQuery{
+		Min:   make(map[string]float64),
+		Max:   make(map[string]float64),
+		Sort:  "lines",
+		Limit: defaultFileListLimit,
+	}
+
+	for _, lang := range splitQueryList(values["language"]) {
+		if q.Languages == nil {
+			q.Languages = make(map[string]bool)
+		}
+		q.Languages[strings.ToLower(lang)] = true
+	}
+	for _, c := range splitQueryList(values["category"]) {
+		category := LanguageCategory(c)
+		if !IsValidCategory(category) {
+			return nil, fmt.Errorf("invalid category %q", c)
+		}
+		if q.Categories == nil {
+			q.Categories = make(map[LanguageCategory]bool)
+		}
+		q.Categories[category] = true
+	}
+	if globs := splitQueryList(values["glob"]); len(globs) > 0 {
+		matcher, err := NewPathMatcher(globs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob: %v", err)
+		}
+		q.Glob = matcher
+	}
+
+	for _, metric := range fileMetrics {
+		for prefix, bounds := range map[string]map[string]float64{"min_": q.Min, "max_": q.Max} {
+			val := values.Get(prefix + metric)
+			if val == "" {
+				continue
+			}
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s%s must be a number", prefix, metric)
+			}
+			bounds[metric] = f
+		}
+	}
+
+	if val := values.Get("sort"); val != "" {
+		if val != "path" && !isFileMetric(val) {
+			return nil, fmt.Errorf("invalid sort %q, expected path or one of %s", val, strings.Join(fileMetrics, ", "))
+		}
+		q.Sort = val
+	}
+	q.Desc = q.Sort != "path"
+	switch values.Get("order") {
+	case "":
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	if val := values.Get("limit"); val != "" {
+		i, err := strconv.Atoi(val)
+		if err != nil || i <= 0 || i > maxFileListLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxFileListLimit)
+		}
+		q.Limit = i
+	}
+	if val := values.Get("cursor"); val != "" {
+		cursor, err := decodeFileCursor(val)
+		if err != nil {
+			return nil, err
+		}
+		q.Cursor = cursor
+	}
+	return q, nil
+}
+
+// ListFiles 按查询条件过滤、排序并分页，返回当前页、满足条件的文件总数和汇总
+// 路径始终按配置的 include/exclude 规则过滤；指定了 category 时列出这些分类的文件，不受 include_* 开关影响
+func ListFiles(stats *RepoStats, cfg Config, q *FileQuery) (page []FileEntry, total int, summary Summary, err error) {
+	effective := cfg.WithRepoConfig(stats.RepoConfig)
+	paths, err := NewPathFilter(effective.IncludePatterns, effective.ExcludePatterns)
+	if err != nil {
+		return nil, 0, Summary{}, newAnalysisError(ErrorInternal, "invalid repository patterns: %v", err)
+	}
+	classifier := NewFileClassifier(effective)
+
+	var matched []FileEntry
+	for _, f := range stats.Files {
+		if keep, _ := paths.Check(f.Path); !keep {
+			continue
+		}
+		category := classifier.Classify(f)
+		if q.Categories != nil {
+			if !q.Categories[category] {
+				continue
+			}
+		} else if !classifier.IncludeCategory(category) {
+			continue
+		}
+		if q.Languages != nil && !q.Languages[strings.ToLower(f.Language)] {
+			continue
+		}
+		if !q.Glob.Empty() {
+			if ok, _ := q.Glob.Match(f.Path); !ok {
+				continue
+			}
+		}
+		entry := FileEntry{FileStat: f, Lines: f.Code + f.Comments + f.Blanks, Category: category}
+		if f.Code+f.Comments > 0 {
+			entry.CommentDensity = float64(f.Comments) / float64(f.Code+f.Comments)
+		}
+		if !q.inBounds(entry) {
+			continue
+		}
+		matched = append(matched, entry)
+		addToStats(&summary, f)
+	}
+
+	// 相同指标按路径排序，保证分页稳定
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if q.Sort != "path" {
+			if va, vb := a.metric(q.Sort), b.metric(q.Sort); va != vb {
+				return va > vb == q.Desc
+			}
+			return a.Path < b.Path
+		}
+		return a.Path < b.Path != q.Desc
+	})
+
+	start := min(q.Cursor.Offset, len(matched))
+	end := min(start+q.Limit, len(matched))
+	return matched[start:end], len(matched), summary, nil
+}
+
+func (q *FileQuery) inBounds(e FileEntry) bool {
+	for metric, bound := range q.Min {
+		if e.metric(metric) < bound {
+			return false
+		}
+	}
+	for metric, bound := range q.Max {
+		if e.metric(metric) > bound {
+			return false
+		}
+	}
+	return true
+}
+
+func isFileMetric(name string) bool {
+	for _, m := range fileMetrics {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// splitQueryList 合并重复的参数并按逗号拆分
+func splitQueryList(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// fileCursor 分页游标：记录第一页所用的提交，翻页时读取同一份分析结果，分支有新提交也不会错位
+type fileCursor struct {
+	Commit string `json:"c,omitempty"`
+	Offset int    `json:"o"`
+}
+
+func encodeFileCursor(cursor fileCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFileCursor(s string) (fileCursor, error) {
+	var cursor fileCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	if err != nil || cursor.Offset < 0 {
+		return fileCursor{}, fmt.Errorf("invalid cursor")
+	}
+	return cursor, nil
+}
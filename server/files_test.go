@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func testRepoStats() *RepoStats {
+	return &RepoStats{
+		Commit: "abc",
+		Files: []FileStat{
+			{Path: "main.go", Language: "Go", Code: 100, Comments: 10, Blanks: 10},
+			{Path: "util.go", Language: "Go", Code: 50, Comments: 60, Blanks: 0},
+			{Path: "util_test.go", Language: "Go", Code: 80, Comments: 0, Blanks: 5},
+			{Path: "web/app.ts", Language: "TypeScript", Code: 100, Comments: 5, Blanks: 15},
+			{Path: "web/app.css", Language: "CSS", Code: 30, Comments: 0, Blanks: 2},
+			{Path: "README.md", Language: "Markdown", Code: 40, Comments: 0, Blanks: 10},
+			{Path: "go.sum", Language: "", Code: 200, Comments: 0, Blanks: 0},
+		},
+	}
+}
+
+func listPaths(t *testing.T, query string) []string {
+	t.Helper()
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ParseFileQuery(values)
+	if err != nil {
+		t.Fatalf("ParseFileQuery(%q): %v", query, err)
+	}
+	page, _, _, err := ListFiles(testRepoStats(), NewAppConfig().Get(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, e := range page {
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+func TestParseFileQueryErrors(t *testing.T) {
+	for _, query := range []string{
+		"category=nope",
+		"glob=/",
+		"min_lines=abc",
+		"sort=size",
+		"order=up",
+		"limit=0",
+		"limit=1001",
+		"cursor=not-base64!",
+		"cursor=" + encodeFileCursor(fileCursor{Offset: -1}),
+	} {
+		values, _ := url.ParseQuery(query)
+		if _, err := ParseFileQuery(values); err == nil {
+			t.Errorf("ParseFileQuery(%q) succeeded, want error", query)
+		}
+	}
+}
+
+func TestListFilesFilterAndSort(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		// 默认按行数降序，相同行数按路径，文档和锁文件按配置不统计
+		{"", []string{"main.go", "web/app.ts", "util.go", "util_test.go", "web/app.css"}},
+		{"sort=path", []string{"main.go", "util.go", "util_test.go", "web/app.css", "web/app.ts"}},
+		{"sort=path&order=desc", []string{"web/app.ts", "web/app.css", "util_test.go", "util.go", "main.go"}},
+		{"sort=code&order=asc", []string{"web/app.css", "util.go", "util_test.go", "main.go", "web/app.ts"}},
+		{"language=go,TYPESCRIPT&sort=path", []string{"main.go", "util.go", "util_test.go", "web/app.ts"}},
+		{"category=test", []string{"util_test.go"}},
+		// 指定分类时不受 include_* 开关影响
+		{"category=documentation&category=lockfile&sort=path", []string{"README.md", "go.sum"}},
+		{"glob=web/&sort=path", []string{"web/app.css", "web/app.ts"}},
+		{"glob=*.go,!*_test.go&sort=path", []string{"main.go", "util.go"}},
+		{"min_comment_density=0.5", []string{"util.go"}},
+		{"min_lines=100&max_code=99", []string{"util.go"}},
+	}
+	for _, c := range cases {
+		if got := listPaths(t, c.query); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.query, got, c.want)
+		}
+	}
+}
+
+func TestListFilesPagination(t *testing.T) {
+	stats := testRepoStats()
+	cfg := NewAppConfig().Get()
+	all := listPaths(t, "sort=path")
+
+	var paged []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(all) {
+			t.Fatal("pagination did not terminate")
+		}
+		values := url.Values{"sort": {"path"}, "limit": {"2"}}
+		if cursor != "" {
+			values.Set("cursor", cursor)
+		}
+		q, err := ParseFileQuery(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, total, summary, err := ListFiles(stats, cfg, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != len(all) {
+			t.Errorf("total = %d, want %d", total, len(all))
+		}
+		// 汇总覆盖全部满足条件的文件，而不是当前页
+		if summary.Code != 100+50+80+100+30 {
+			t.Errorf("summary.Code = %d, want the total over all matched files", summary.Code)
+		}
+		for _, e := range page {
+			paged = append(paged, e.Path)
+		}
+		next := q.Cursor.Offset + len(page)
+		if next >= total {
+			break
+		}
+		cursor = encodeFileCursor(fileCursor{Commit: stats.Commit, Offset: next})
+	}
+	if !reflect.DeepEqual(paged, all) {
+		t.Errorf("paged = %v, want %v", paged, all)
+	}
+
+	// 超出范围的游标返回空页
+	q, _ := ParseFileQuery(url.Values{"cursor": {encodeFileCursor(fileCursor{Offset: 100})}})
+	if page, _, _, _ := ListFiles(stats, cfg, q); len(page) != 0 {
+		t.Errorf("page past the end = %v, want empty", page)
+	}
+}
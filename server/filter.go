@@ -0,0 +1,84 @@
+package main
+
+import "sort"
+
+// FilterResult 按路径规则和分类过滤后的结果
+type FilterResult struct {
+	Files     []FileStat
+	Lockfiles LockfileStat
+	Excluded  ExcludedReport
+}
+
+// ApplyFilters 对缓存的完整文件列表按当前配置进行过滤，并汇总被过滤的内容
+// 先应用路径规则，再按分类过滤
+func ApplyFilters(stats *RepoStats, classifier *FileClassifier, paths *PathFilter) FilterResult {
+	result := FilterResult{
+		Files:     make([]FileStat, 0, len(stats.Files)),
+		Lockfiles: LockfileStat{Skipped: !classifier.IncludeCategory(CategoryLockfile)},
+	}
+
+	byPattern := make(map[string]*ExcludedPatternStat)
+	byCategory := make(map[LanguageCategory]*ExcludedCategoryStat)
+
+	for _, f := range stats.Files {
+		if keep, rule := paths.Check(f.Path); !keep {
+			if rule == "" {
+				result.Excluded.NotIncluded.Add(f)
+				continue
+			}
+			stat, ok := byPattern[rule]
+			if !ok {
+				stat = &ExcludedPatternStat{Pattern: rule}
+				byPattern[rule] = stat
+			}
+			stat.Add(f)
+			continue
+		}
+
+		category := classifier.Classify(f)
+		if category == CategoryLockfile {
+			result.Lockfiles.Files++
+			result.Lockfiles.Lines += f.Code + f.Comments + f.Blanks
+		}
+
+		if classifier.IncludeCategory(category) {
+			result.Files = append(result.Files, f)
+			continue
+		}
+
+		stat, ok := byCategory[category]
+		if !ok {
+			stat = &ExcludedCategoryStat{Category: category}
+			byCategory[category] = stat
+		}
+		stat.Add(f)
+	}
+
+	// 按排除行数降序输出，保证结果稳定
+	result.Excluded.Patterns = make([]ExcludedPatternStat, 0, len(byPattern))
+	for _, stat := range byPattern {
+		result.Excluded.Patterns = append(result.Excluded.Patterns, *stat)
+	}
+	sort.Slice(result.Excluded.Patterns, func(i, j int) bool {
+		a, b := result.Excluded.Patterns[i], result.Excluded.Patterns[j]
+		if a.Lines != b.Lines {
+			return a.Lines > b.Lines
+		}
+		return a.Pattern < b.Pattern
+	})
+
+	// 按分类展示顺序输出，保证结果稳定
+	result.Excluded.Categories = make([]ExcludedCategoryStat, 0, len(byCategory))
+	for _, category := range AllCategories {
+		if stat, ok := byCategory[category]; ok {
+			result.Excluded.Categories = append(result.Excluded.Categories, *stat)
+		}
+	}
+
+	result.Excluded.Dirs = stats.ExcludedDirs
+	if result.Excluded.Dirs == nil {
+		result.Excluded.Dirs = []ExcludedDirStat{}
+	}
+
+	return result
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GlobPattern gitignore 风格的路径匹配规则
+// 支持 *、?、[...]、**，前导 / 表示锚定仓库根目录，末尾 / 表示只匹配目录，前导 ! 表示取反
+type GlobPattern struct {
+	Raw    string
+	negate bool
+	re     *regexp.Regexp
+}
+
+// CompileGlob 将 gitignore 风格的规则编译为正则
+func CompileGlob(pattern string) (*GlobPattern, error) {
+	raw := pattern
+	p := strings.TrimSpace(pattern)
+	if p == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	negate := false
+	if strings.HasPrefix(p, "!") {
+		negate = true
+		p = p[1:]
+	}
+
+	dirOnly := false
+	if strings.HasSuffix(p, "/") {
+		dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+
+	// 包含 / 的规则相对仓库根目录匹配，否则匹配任意层级
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return nil, fmt.Errorf("invalid pattern %q", raw)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored {
+		sb.WriteString("(?:.*/)?")
+	}
+
+	// 按 rune 处理，非 ASCII 的规则（如 文档/**）按字符而不是字节转义
+	runes := []rune(p)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		switch ch {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				// **/ 匹配零或多级目录，末尾的 /** 匹配目录下所有内容
+				if i+2 < len(runes) && runes[i+2] == '/' {
+					sb.WriteString("(?:.*/)?")
+					i += 2
+				} else {
+					sb.WriteString(".*")
+					i++
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			class, n, ok := globClass(runes[i+1:])
+			if !ok {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString(class)
+			i += n
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+
+	// 匹配到目录时，目录下的所有文件都视为命中
+	if dirOnly {
+		sb.WriteString("/.*$")
+	} else {
+		sb.WriteString("(?:/.*)?$")
+	}
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", raw, err)
+	}
+	return &GlobPattern{Raw: raw, negate: negate, re: re}, nil
+}
+
+// globClass 将 [ 之后的字符集转换为正则，返回正则和消耗的 rune 数（含结尾的 ]），没有结尾的 ] 时 ok 为 false
+// 与 gitignore 一致：开头的 ! 或 ^ 表示取反，紧跟在开头的 ] 是普通字符，\ 转义下一个字符
+func globClass(rs []rune) (class string, n int, ok bool) {
+	var sb strings.Builder
+	sb.WriteString("[")
+	j := 0
+	if j < len(rs) && (rs[j] == '!' || rs[j] == '^') {
+		sb.WriteString("^")
+		j++
+	}
+	for first := true; j < len(rs); j, first = j+1, false {
+		r := rs[j]
+		switch {
+		case r == ']' && !first:
+			sb.WriteString("]")
+			return sb.String(), j + 1, true
+		case r == '\\' && j+1 < len(rs):
+			j++
+			if strings.ContainsRune(`\[]^-`, rs[j]) {
+				sb.WriteString(`\`)
+			}
+			sb.WriteRune(rs[j])
+		default:
+			if strings.ContainsRune(`\[]^`, r) {
+				sb.WriteString(`\`)
+			}
+			sb.WriteRune(r)
+		}
+	}
+	return "", 0, false
+}
+
+// PathMatcher 一组按顺序生效的路径规则，后面的规则覆盖前面的规则
+type PathMatcher struct {
+	patterns []*GlobPattern
+}
+
+// NewPathMatcher 编译一组规则，忽略空行和 # 开头的注释
+func NewPathMatcher(patterns []string) (*PathMatcher, error) {
+	m := &PathMatcher{}
+	for _, p := range patterns {
+		trimmed := strings.TrimSpace(p)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		g, err := CompileGlob(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		m.patterns = append(m.patterns, g)
+	}
+	return m, nil
+}
+
+// Empty 判断是否没有任何规则
+func (m *PathMatcher) Empty() bool {
+	return m == nil || len(m.patterns) == 0
+}
+
+// Match 判断路径是否命中规则，返回最终生效的规则
+func (m *PathMatcher) Match(filePath string) (bool, string) {
+	if m == nil {
+		return false, ""
+	}
+	cleanPath := strings.TrimPrefix(strings.ReplaceAll(filePath, "\\", "/"), "./")
+
+	matched := false
+	rule := ""
+	for _, g := range m.patterns {
+		if g.re.MatchString(cleanPath) {
+			matched = !g.negate
+			rule = g.Raw
+		}
+	}
+	if !matched {
+		return false, ""
+	}
+	return true, rule
+}
+
+// PathFilter 基于路径的包含/排除规则
+type PathFilter struct {
+	include *PathMatcher
+	exclude *PathMatcher
+}
+
+// NewPathFilter 创建路径过滤器，include 为空时包含所有文件
+func NewPathFilter(include []string, exclude []string) (*PathFilter, error) {
+	inc, err := NewPathMatcher(include)
+	if err != nil {
+		return nil, fmt.Errorf("include pattern: %v", err)
+	}
+	exc, err := NewPathMatcher(exclude)
+	if err != nil {
+		return nil, fmt.Errorf("exclude pattern: %v", err)
+	}
+	return &PathFilter{include: inc, exclude: exc}, nil
+}
+
+// Check 判断文件是否保留；被排除时返回命中的排除规则，未命中 include 规则时返回空字符串
+func (f *PathFilter) Check(filePath string) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+	if !f.include.Empty() {
+		if ok, _ := f.include.Match(filePath); !ok {
+			return false, ""
+		}
+	}
+	if ok, rule := f.exclude.Match(filePath); ok {
+		return false, rule
+	}
+	return true, ""
+}
@@ -0,0 +1,127 @@
+package main
+
+import "testing"
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// 不含 / 的规则匹配任意层级
+		{"*.go", "main.go", true},
+		{"*.go", "server/main.go", true},
+		{"*.go", "main.go.txt", false},
+		{"vendor", "vendor/a/b.go", true},
+		{"vendor", "src/vendor/b.go", true},
+		// 含 / 的规则锚定根目录
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"docs/*.md", "x/docs/a.md", false},
+		{"/build", "build/out.js", true},
+		{"/build", "src/build/out.js", false},
+		// **
+		{"**/testdata/**", "a/b/testdata/c.json", true},
+		{"**/testdata/**", "testdata/c.json", true},
+		{"internal/**", "internal/a/b.go", true},
+		{"internal/**", "pkg/internal/a.go", false},
+		{"a/**/b.go", "a/b.go", true},
+		{"a/**/b.go", "a/x/y/b.go", true},
+		// 末尾 / 只匹配目录
+		{"gen/", "gen/a.go", true},
+		{"gen/", "gen", false},
+		// ?
+		{"?.go", "a.go", true},
+		{"?.go", "ab.go", false},
+		{"a?b", "a/b", false},
+		// 字符集
+		{"[abc].go", "b.go", true},
+		{"[abc].go", "d.go", false},
+		{"[!abc].go", "d.go", true},
+		{"[^abc].go", "a.go", false},
+		{"[a-c].go", "b.go", true},
+		{"[]]x", "]x", true},
+		{"[]]x", "ax", false},
+		{"[!]]x", "ax", true},
+		{`[\]]x`, "]x", true},
+		{`[a\-c].go`, "-.go", true},
+		{`[a\-c].go`, "b.go", false},
+		{"[abc", "[abc", true},
+		// 转义和正则特殊字符
+		{`\*.go`, "*.go", true},
+		{`\*.go`, "a.go", false},
+		{"a+b(1).go", "a+b(1).go", true},
+		{"a.go", "aXgo", false},
+		// 非 ASCII
+		{"文档/**", "文档/a.go", true},
+		{"文档/**", "文件/a.go", false},
+		{"*.测试", "a/b.测试", true},
+		{"[文档]", "档", true},
+		{"?", "档", true},
+	}
+	for _, tt := range tests {
+		g, err := CompileGlob(tt.pattern)
+		if err != nil {
+			t.Errorf("CompileGlob(%q) error: %v", tt.pattern, err)
+			continue
+		}
+		if got := g.re.MatchString(tt.path); got != tt.want {
+			t.Errorf("CompileGlob(%q) match %q = %v, want %v (regexp %s)", tt.pattern, tt.path, got, tt.want, g.re)
+		}
+	}
+}
+
+func TestCompileGlobInvalid(t *testing.T) {
+	for _, pattern := range []string{"", "  ", "/", "!/"} {
+		if _, err := CompileGlob(pattern); err == nil {
+			t.Errorf("CompileGlob(%q) succeeded, want error", pattern)
+		}
+	}
+}
+
+func TestPathMatcher(t *testing.T) {
+	m, err := NewPathMatcher([]string{"# comment", "*.log", "!keep.log", "", "logs/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		want     bool
+		wantRule string
+	}{
+		{"a.log", true, "*.log"},
+		{"keep.log", false, ""},
+		{"logs/keep.log", true, "logs/"},
+		{`logs\b.txt`, true, "logs/"},
+		{"./c.log", true, "*.log"},
+		{"main.go", false, ""},
+	}
+	for _, tt := range tests {
+		got, rule := m.Match(tt.path)
+		if got != tt.want || rule != tt.wantRule {
+			t.Errorf("Match(%q) = %v, %q, want %v, %q", tt.path, got, rule, tt.want, tt.wantRule)
+		}
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	f, err := NewPathFilter([]string{"src/**"}, []string{"**/*_gen.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		keep     bool
+		wantRule string
+	}{
+		{"src/a.go", true, ""},
+		{"src/a_gen.go", false, "**/*_gen.go"},
+		{"docs/a.md", false, ""},
+	}
+	for _, tt := range tests {
+		keep, rule := f.Check(tt.path)
+		if keep != tt.keep || rule != tt.wantRule {
+			t.Errorf("Check(%q) = %v, %q, want %v, %q", tt.path, keep, rule, tt.keep, tt.wantRule)
+		}
+	}
+}
@@ -2,9 +2,13 @@ module goloc
 
 go 1.25.4
 
+require (
+	github.com/google/uuid v1.6.0
+	github.com/hhatto/gocloc v0.7.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/go-enry/go-enry/v2 v2.8.0 // indirect
 	github.com/go-enry/go-oniguruma v1.2.1 // indirect
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/hhatto/gocloc v0.7.0 // indirect
 )
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// flight 一次正在进行的分析
+type flight struct {
+	done     chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	waiters  int      // 仍在等待结果的调用方数量
+	priority Priority // 等待者中最高的优先级，由 FlightGroup.mu 保护
+	tracker  *Tracker
+	stats    *RepoStats
+	err      error
+}
+
+// FlightGroup 合并同一缓存键上并发的分析请求：同一时刻只运行一次克隆/统计，
+// 所有等待者拿到同一份结果（包括错误）
+type FlightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+func NewFlightGroup() *FlightGroup {
+	return &FlightGroup{flights: make(map[string]*flight)}
+}
+
+// Do 执行或加入 key 对应的分析，shared 表示加入了已在进行的分析
+// fn 在独立的 goroutine 中运行，其 ctx 不属于任何单个调用方：发起者断开后分析继续，
+// 其余等待者照常拿到结果；调用方 ctx 结束时只是自己不再等待，返回 ctx.Err()，
+// 最后一个等待者离开时才取消分析（终止 git 进程并清理临时目录）
+// observe 非空时接收该次分析的进度（加入时会先收到当前阶段）
+// 分析按 ctx 中的优先级排队，更高优先级的调用方加入时提升排队中的分析的优先级
+func (g *FlightGroup) Do(ctx context.Context, key string, fn func(context.Context, *Tracker) (*RepoStats, error), observe func(ProgressEvent)) (stats *RepoStats, shared bool, err error) {
+	priority := priorityFrom(ctx)
+	promote := false
+
+	g.mu.Lock()
+	f, shared := g.flights[key]
+	if !shared {
+		f = &flight{done: make(chan struct{}), tracker: NewTracker(), priority: priority}
+		// 分析按 flight 当前的优先级排队，而不是发起时的优先级，见 withPriorityFunc
+		f.ctx, f.cancel = context.WithCancel(withPriorityFunc(context.Background(), func() Priority {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			return f.priority
+		}))
+		g.flights[key] = f
+	} else if priority.rank() < f.priority.rank() {
+		f.priority = priority
+		promote = true
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	// 更高优先级的请求加入了排队中的分析，按新的优先级排队
+	if promote {
+		workers.Promote(f.tracker, priority)
+	}
+
+	// 先注册观察者再启动分析，发起者不会错过最早的进度
+	f.tracker.Observe(observe)
+	if !shared {
+		go g.run(key, f, fn)
+	}
+
+	select {
+	case <-f.done:
+		return f.stats, shared, f.err
+	case <-ctx.Done():
+		g.leave(key, f)
+		return nil, shared, ctx.Err()
+	}
+}
+
+// leave 等待者离开，没有其他等待者时取消分析
+// 取消的分析立即从记录中移除，之后的请求会重新发起分析而不是加入一个即将失败的分析
+func (g *FlightGroup) leave(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+	select {
+	case <-f.done:
+		return
+	default:
+	}
+	fmt.Println("[Analysis] Canceled, no waiters left:", key)
+	f.cancel()
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// run 执行分析，结束后移除记录再唤醒等待者，之后的请求会重新查缓存
+func (g *FlightGroup) run(key string, f *flight, fn func(context.Context, *Tracker) (*RepoStats, error)) {
+	defer func() {
+		// 分析在请求的 goroutine 之外运行，recoveryMiddleware 捕获不到，这里需要自行恢复
+		if r := recover(); r != nil {
+			log.Printf("[PANIC] analysis %s: %v\n%s", key, r, string(debug.Stack()))
+			f.stats, f.err = nil, fmt.Errorf("internal error: %v", r)
+		}
+		g.mu.Lock()
+		if g.flights[key] == f {
+			delete(g.flights, key)
+		}
+		g.mu.Unlock()
+		f.cancel()
+		close(f.done)
+	}()
+
+	f.stats, f.err = fn(f.ctx, f.tracker)
+}
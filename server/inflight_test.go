@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 更高优先级的请求在分析排队之前加入时，分析按提升后的优先级排队
+func TestFlightGroupPromotesBeforeQueueing(t *testing.T) {
+	saved := workers
+	defer func() { workers = saved }()
+	workers = NewWorkerPool(PoolLimits{Workers: 1, QueueSize: 10})
+
+	// 占满唯一的槽位
+	hold, err := workers.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewFlightGroup()
+	gate := make(chan struct{})
+	fn := func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+		<-gate
+		release, err := workers.Acquire(ctx, tracker)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return &RepoStats{Commit: "abc"}, nil
+	}
+
+	results := make(chan error, 2)
+	go func() {
+		_, _, err := g.Do(WithPriority(context.Background(), PriorityBackground), "key", fn, nil)
+		results <- err
+	}()
+	waitFor(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.flights["key"] != nil
+	})
+	go func() {
+		_, shared, err := g.Do(WithPriority(context.Background(), PriorityInteractive), "key", fn, nil)
+		if !shared {
+			t.Error("second caller did not join the flight")
+		}
+		results <- err
+	}()
+	waitFor(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.flights["key"].priority == PriorityInteractive
+	})
+
+	close(gate)
+	waitFor(t, func() bool {
+		workers.mu.Lock()
+		defer workers.mu.Unlock()
+		return len(workers.classes[PriorityInteractive].queue) == 1
+	})
+	if n := len(workers.classes[PriorityBackground].queue); n != 0 {
+		t.Errorf("%d analyses queued as background, want 0", n)
+	}
+
+	hold()
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("Do() error: %v", err)
+		}
+	}
+}
+
+// 最后一个等待者离开时取消分析，之前离开的等待者不影响其他人
+func TestFlightGroupCancelsWhenLastWaiterLeaves(t *testing.T) {
+	g := NewFlightGroup()
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() { _, _, err := g.Do(ctx1, "key", fn, nil); errs <- err }()
+	waitFor(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.flights["key"] != nil
+	})
+	go func() { _, _, err := g.Do(ctx2, "key", fn, nil); errs <- err }()
+	waitFor(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.flights["key"].waiters == 2
+	})
+
+	cancel1()
+	<-errs
+	select {
+	case <-canceled:
+		t.Fatal("flight canceled while a waiter remained")
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel2()
+	<-errs
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("flight not canceled after the last waiter left")
+	}
+}
+
+// flightWaitersIn 返回 g 中 key 的等待者数量，没有进行中的分析时为 0
+func flightWaitersIn(g *FlightGroup, key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f, ok := g.flights[key]; ok {
+		return f.waiters
+	}
+	return 0
+}
+
+type flightResult struct {
+	stats  *RepoStats
+	shared bool
+	err    error
+}
+
+// doConcurrently 让 n 个调用方同时请求 key，全部加入后放行 fn 并收集结果
+func doConcurrently(t *testing.T, g *FlightGroup, key string, n int, gate chan struct{}, fn func(context.Context, *Tracker) (*RepoStats, error)) []flightResult {
+	t.Helper()
+	results := make(chan flightResult, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			stats, shared, err := g.Do(context.Background(), key, fn, nil)
+			results <- flightResult{stats, shared, err}
+		}()
+	}
+	waitFor(t, func() bool { return flightWaitersIn(g, key) == n })
+	close(gate)
+
+	var all []flightResult
+	for i := 0; i < n; i++ {
+		all = append(all, <-results)
+	}
+	return all
+}
+
+// 同一 key 的并发调用只执行一次 fn，所有调用方拿到发起者的结果和错误
+func TestFlightGroupCoalesces(t *testing.T) {
+	const n = 10
+	g := NewFlightGroup()
+	var calls atomic.Int64
+	want := &RepoStats{Commit: "abc"}
+	gate := make(chan struct{})
+	results := doConcurrently(t, g, "key", n, gate, func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+		calls.Add(1)
+		<-gate
+		return want, nil
+	})
+
+	if c := calls.Load(); c != 1 {
+		t.Errorf("fn ran %d times, want 1", c)
+	}
+	leaders := 0
+	for _, r := range results {
+		if r.err != nil || r.stats != want {
+			t.Errorf("Do() = %+v, %v, want the leader's result", r.stats, r.err)
+		}
+		if !r.shared {
+			leaders++
+		}
+	}
+	if leaders != 1 {
+		t.Errorf("%d callers reported shared=false, want 1", leaders)
+	}
+
+	// 失败同样共享给所有等待者
+	boom := errors.New("boom")
+	gate = make(chan struct{})
+	results = doConcurrently(t, g, "key", n, gate, func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+		calls.Add(1)
+		<-gate
+		return nil, boom
+	})
+	for _, r := range results {
+		if r.err != boom || r.stats != nil {
+			t.Errorf("Do() = %+v, %v, want the leader's error", r.stats, r.err)
+		}
+	}
+	// 结束后移除记录，下一次调用重新执行
+	if c := calls.Load(); c != 2 {
+		t.Errorf("fn ran %d times in total, want 2", c)
+	}
+}
+
+// 不同 key 的分析互不合并
+func TestFlightGroupSeparateKeys(t *testing.T) {
+	g := NewFlightGroup()
+	gate := make(chan struct{})
+	fn := func(commit string) func(context.Context, *Tracker) (*RepoStats, error) {
+		return func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+			<-gate
+			return &RepoStats{Commit: commit}, nil
+		}
+	}
+	results := make(chan *RepoStats, 2)
+	for _, key := range []string{"a", "b"} {
+		go func() {
+			stats, shared, _ := g.Do(context.Background(), key, fn(key), nil)
+			if shared {
+				t.Errorf("%s joined another key's flight", key)
+			}
+			results <- stats
+		}()
+	}
+	waitFor(t, func() bool { return flightWaitersIn(g, "a") == 1 && flightWaitersIn(g, "b") == 1 })
+	close(gate)
+	got := map[string]bool{(<-results).Commit: true, (<-results).Commit: true}
+	if !got["a"] || !got["b"] {
+		t.Errorf("results = %v, want one per key", got)
+	}
+}
+
+// fn panic 时所有等待者收到错误，记录被移除，之后的调用正常执行
+func TestFlightGroupRecoversPanic(t *testing.T) {
+	g := NewFlightGroup()
+	gate := make(chan struct{})
+	results := doConcurrently(t, g, "key", 3, gate, func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+		<-gate
+		panic("boom")
+	})
+	for _, r := range results {
+		if r.err == nil || !strings.Contains(r.err.Error(), "internal error: boom") {
+			t.Errorf("Do() error = %v, want internal error", r.err)
+		}
+	}
+	if flightWaitersIn(g, "key") != 0 {
+		t.Error("panicked flight still registered")
+	}
+
+	stats, shared, err := g.Do(context.Background(), "key", func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+		return &RepoStats{Commit: "abc"}, nil
+	}, nil)
+	if err != nil || shared || stats.Commit != "abc" {
+		t.Errorf("Do() after panic = %+v, %v, %v", stats, shared, err)
+	}
+}
+
+// 中途加入的调用方先收到当前阶段，之后与发起者收到相同的进度
+func TestFlightGroupSharesProgress(t *testing.T) {
+	g := NewFlightGroup()
+	step := make(chan struct{})
+	fn := func(ctx context.Context, tracker *Tracker) (*RepoStats, error) {
+		tracker.Report(ProgressEvent{Stage: StageCloning})
+		<-step
+		tracker.Report(ProgressEvent{Stage: StageCounting})
+		return &RepoStats{}, nil
+	}
+	var mu sync.Mutex
+	seen := map[string][]Stage{}
+	observe := func(name string) func(ProgressEvent) {
+		return func(e ProgressEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[name] = append(seen[name], e.Stage)
+		}
+	}
+	stages := func(name string) []Stage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Stage{}, seen[name]...)
+	}
+
+	done := make(chan struct{}, 2)
+	go func() { g.Do(context.Background(), "key", fn, observe("leader")); done <- struct{}{} }()
+	waitFor(t, func() bool { return len(stages("leader")) == 1 })
+	go func() { g.Do(context.Background(), "key", fn, observe("joiner")); done <- struct{}{} }()
+	waitFor(t, func() bool { return flightWaitersIn(g, "key") == 2 })
+	close(step)
+	<-done
+	<-done
+
+	want := []Stage{StageCloning, StageCounting}
+	for _, name := range []string{"leader", "joiner"} {
+		if got := stages(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s saw %v, want %v", name, got, want)
+		}
+	}
+}
+
+// waitFor 轮询直到条件成立，超时则失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
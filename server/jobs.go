@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobState 分析任务的状态
+type JobState string
+
+const (
+	JobQueued   JobState = "queued"
+	JobCloning  JobState = "cloning"
+	JobCounting JobState = "counting"
+	JobDone     JobState = "done"
+	JobFailed   JobState = "failed"
+	JobCanceled JobState = "canceled"
+)
+
+// jobRetention 任务结束后保留多久，超过后查询返回不存在
+const jobRetention = time.Hour
+
+// maxRetainedJobs 保留的任务数上限，超出时提前清理最早结束的任务
+// 结束的任务带着完整的分析结果，不计入缓存的内存预算，需要单独限制
+const maxRetainedJobs = 1000
+
+// JobKind 任务类型
+type JobKind string
+
+const (
+	JobAnalyze JobKind = "analyze" // 单个仓库分析，结果为 AnalyzeResult
+	JobBatch   JobKind = "batch"   // 批量分析，结果为 BatchResult
+	JobOrg     JobKind = "org"     // 组织/用户级分析，结果为 OrgResult
+)
+
+// JobStatus 任务状态，时间为 Unix 秒，耗时为毫秒
+type JobStatus struct {
+	ID         string         `json:"id"`
+	Kind       JobKind        `json:"kind"`
+	State      JobState       `json:"state"`
+	Repo       string         `json:"repo"`
+	Branch     string         `json:"branch"`
+	Owner      string         `json:"owner,omitempty"` // 组织/用户级分析的组织或用户名
+	Priority   Priority       `json:"priority"`
+	CreatedAt  int64          `json:"created_at"`
+	StartedAt  int64          `json:"started_at,omitempty"`
+	FinishedAt int64          `json:"finished_at,omitempty"`
+	QueuedMs   int64          `json:"queued_ms"`          // 从提交到开始执行（包括在分析队列中等待的时间）
+	ElapsedMs  int64          `json:"elapsed_ms"`         // 从开始执行到结束（未结束时到当前）
+	Progress   *ProgressEvent `json:"progress,omitempty"` // 最近一次的进度，命中缓存时为空
+	Result     interface{}    `json:"result,omitempty"`   // 按任务类型为 AnalyzeResult、BatchResult 或 OrgResult
+	Error      *AnalysisError `json:"error,omitempty"`
+}
+
+// jobTask 任务的执行内容，ctx 带有任务的优先级，结束时返回结果
+type jobTask func(ctx context.Context, job *Job) (interface{}, error)
+
+// Job 一次分析任务
+type Job struct {
+	kind     JobKind
+	repo     string
+	branch   string
+	owner    string
+	task     jobTask
+	priority Priority
+	done     chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu         sync.Mutex
+	id         string
+	state      JobState
+	createdAt  time.Time
+	startedAt  time.Time
+	finishedAt time.Time
+	progress   ProgressEvent
+	result     interface{}
+	err        *AnalysisError
+	watchers   map[chan ProgressEvent]struct{}
+}
+
+// jobWatcherBuffer 订阅者的进度缓冲，消费不及时的订阅者会丢弃中间的进度
+const jobWatcherBuffer = 16
+
+// Done 任务结束（成功、失败或取消）时关闭
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Status 返回任务状态的快照
+func (j *Job) Status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	status := JobStatus{
+		ID:        j.id,
+		Kind:      j.kind,
+		State:     j.state,
+		Repo:      j.repo,
+		Branch:    j.branch,
+		Owner:     j.owner,
+		Priority:  j.priority,
+		CreatedAt: j.createdAt.Unix(),
+		Result:    j.result,
+		Error:     j.err,
+	}
+	if !j.startedAt.IsZero() {
+		status.StartedAt = j.startedAt.Unix()
+		status.QueuedMs = j.startedAt.Sub(j.createdAt).Milliseconds()
+		end := j.finishedAt
+		if end.IsZero() {
+			end = time.Now()
+		}
+		status.ElapsedMs = end.Sub(j.startedAt).Milliseconds()
+	} else {
+		status.QueuedMs = time.Since(j.createdAt).Milliseconds()
+	}
+	if !j.finishedAt.IsZero() {
+		status.FinishedAt = j.finishedAt.Unix()
+	}
+	if j.progress.Stage != "" {
+		progress := j.progress
+		status.Progress = &progress
+	}
+	return status
+}
+
+// Subscribe 订阅任务进度，任务结束时关闭通道；任务已结束时返回已关闭的通道
+// 调用方不再读取时需调用返回的函数取消订阅
+func (j *Job) Subscribe() (<-chan ProgressEvent, func()) {
+	ch := make(chan ProgressEvent, jobWatcherBuffer)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.finishedAt.IsZero() {
+		close(ch)
+		return ch, func() {}
+	}
+	if j.watchers == nil {
+		j.watchers = make(map[chan ProgressEvent]struct{})
+	}
+	j.watchers[ch] = struct{}{}
+
+	return ch, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.watchers[ch]; ok {
+			delete(j.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// start 记录开始执行的时间，此后命中缓存的任务直接结束，需要克隆的任务进入 cloning
+func (j *Job) start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.startedAt = time.Now()
+}
+
+// onProgress 记录分析进度并转发给订阅者，同时映射为任务状态
+// 检查仓库信息归入 cloning，生成目录树归入 counting，等待分析槽位时保持 queued
+func (j *Job) onProgress(event ProgressEvent) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished() {
+		return
+	}
+
+	// 在分析队列中等待的时间计入排队耗时，获得槽位后重新开始计时
+	if j.state == JobQueued && event.Stage != StageQueued && j.progress.Stage == StageQueued {
+		j.startedAt = time.Now()
+	}
+	switch event.Stage {
+	case StageQueued:
+		j.state = JobQueued
+	case StageMetadata, StageCloning:
+		j.state = JobCloning
+	case StageCounting, StageBuilding:
+		j.state = JobCounting
+	}
+	j.progress = event
+	for ch := range j.watchers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Cancel 取消任务，任务已结束时返回 false
+// 任务离开正在进行的分析，没有其他请求等待同一分析时终止 git 进程并清理临时目录
+func (j *Job) Cancel() bool {
+	j.mu.Lock()
+	finished := j.finished()
+	j.mu.Unlock()
+	if finished {
+		return false
+	}
+	j.cancel()
+	return true
+}
+
+// finished 任务是否已结束，调用方需持有锁
+func (j *Job) finished() bool {
+	return j.state == JobDone || j.state == JobFailed || j.state == JobCanceled
+}
+
+func (j *Job) finish(result interface{}, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.finishedAt = time.Now()
+	if j.startedAt.IsZero() {
+		j.startedAt = j.finishedAt
+	}
+	if err != nil && j.ctx.Err() != nil {
+		j.state = JobCanceled
+		j.err = errCanceled
+	} else if err != nil {
+		j.state = JobFailed
+		j.err = asAnalysisError(err)
+	} else {
+		j.state = JobDone
+		j.result = result
+	}
+	for ch := range j.watchers {
+		close(ch)
+	}
+	j.watchers = nil
+	j.cancel()
+	close(j.done)
+}
+
+// finishedTime 任务结束的时间，未结束时为零值
+func (j *Job) finishedTime() time.Time {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.finishedAt
+}
+
+// finishedBefore 任务是否在指定时间之前结束
+func (j *Job) finishedBefore(t time.Time) bool {
+	finishedAt := j.finishedTime()
+	return !finishedAt.IsZero() && finishedAt.Before(t)
+}
+
+// JobManager 管理分析任务，任务结束后保留 jobRetention 供查询，最多保留 maxRetainedJobs 个
+type JobManager struct {
+	jobs map[string]*Job
+	mu   sync.RWMutex
+}
+
+func NewJobManager(cleanInterval time.Duration) *JobManager {
+	m := &JobManager{jobs: make(map[string]*Job)}
+	go m.startCleaner(cleanInterval)
+	return m
+}
+
+func (m *JobManager) startCleaner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.cleanup()
+	}
+}
+
+func (m *JobManager) cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deadline := time.Now().Add(-jobRetention)
+	for id, job := range m.jobs {
+		if job.finishedBefore(deadline) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// Submit 创建单个仓库的分析任务并在后台执行，任务不随提交它的 HTTP 请求结束而取消，需要时调用 Job.Cancel
+// priority 决定任务在分析队列中的优先级
+func (m *JobManager) Submit(req AnalyzeRequest, cfg Config, priority Priority) *Job {
+	job := &Job{
+		kind:   JobAnalyze,
+		repo:   req.RepoURL,
+		branch: req.Branch,
+		task: func(ctx context.Context, job *Job) (interface{}, error) {
+			loaded, err := LoadRepoStats(ctx, req.RepoURL, req.Branch, cfg, job.onProgress)
+			if err != nil {
+				return nil, err
+			}
+			job.onProgress(ProgressEvent{Stage: StageBuilding})
+			return BuildAnalyzeResult(req, cfg, loaded)
+		},
+	}
+	m.submit(job, priority)
+	fmt.Printf("[Job] Submitted %s: %s (branch: %s, priority: %s)\n", job.id, req.RepoURL, req.Branch, priority)
+	return job
+}
+
+// SubmitBatch 创建批量分析任务，进度的 percent 为已完成的仓库比例
+func (m *JobManager) SubmitBatch(req BatchAnalyzeRequest, cfg Config, priority Priority) *Job {
+	job := &Job{
+		kind: JobBatch,
+		task: func(ctx context.Context, job *Job) (interface{}, error) {
+			return AnalyzeBatch(ctx, req.Repos, cfg, func(done, total int) {
+				job.onProgress(ProgressEvent{Stage: StageCounting, Percent: done * 100 / total})
+			}), nil
+		},
+	}
+	m.submit(job, priority)
+	fmt.Printf("[Job] Submitted %s: batch of %d repos (priority: %s)\n", job.id, len(req.Repos), priority)
+	return job
+}
+
+// SubmitOrg 创建组织/用户级分析任务，进度的 percent 为已完成的仓库比例
+func (m *JobManager) SubmitOrg(req OrgAnalyzeRequest, filter *OwnerRepoFilter, cfg Config, priority Priority) *Job {
+	job := &Job{
+		kind:  JobOrg,
+		owner: normalizeOwner(req.Owner),
+		task: func(ctx context.Context, job *Job) (interface{}, error) {
+			job.onProgress(ProgressEvent{Stage: StageMetadata})
+			return AnalyzeOwner(ctx, req, filter, cfg, func(done, total int) {
+				job.onProgress(ProgressEvent{Stage: StageCounting, Percent: done * 100 / total})
+			})
+		},
+	}
+	m.submit(job, priority)
+	fmt.Printf("[Job] Submitted %s: owner %s (priority: %s)\n", job.id, job.owner, priority)
+	return job
+}
+
+// submit 登记任务并在后台执行
+func (m *JobManager) submit(job *Job, priority Priority) {
+	job.ctx, job.cancel = context.WithCancel(context.Background())
+	job.priority = priority
+	job.done = make(chan struct{})
+	job.id = uuid.New().String()
+	job.state = JobQueued
+	job.createdAt = time.Now()
+
+	m.mu.Lock()
+	m.jobs[job.id] = job
+	m.evictLocked()
+	m.mu.Unlock()
+
+	go m.run(job)
+}
+
+// evictLocked 任务数超过上限时按结束时间从早到晚清理已结束的任务，进行中的任务不清理，调用方需持有锁
+func (m *JobManager) evictLocked() {
+	if len(m.jobs) <= maxRetainedJobs {
+		return
+	}
+	type finishedJob struct {
+		id string
+		at time.Time
+	}
+	var finished []finishedJob
+	for id, job := range m.jobs {
+		if at := job.finishedTime(); !at.IsZero() {
+			finished = append(finished, finishedJob{id, at})
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].at.Before(finished[j].at) })
+	evicted := 0
+	for _, f := range finished {
+		if len(m.jobs) <= maxRetainedJobs {
+			break
+		}
+		delete(m.jobs, f.id)
+		evicted++
+	}
+	fmt.Printf("[Job] Evicted %d finished jobs over the limit of %d\n", evicted, maxRetainedJobs)
+}
+
+// Remove 删除任务；同步分析的任务在返回结果后即删除，不占用保留名额
+func (m *JobManager) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.jobs, id)
+}
+
+// Get 查询任务
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, found := m.jobs[id]
+	return job, found
+}
+
+func (m *JobManager) run(job *Job) {
+	var result interface{}
+	var err error
+	defer func() {
+		// 任务在请求的 goroutine 之外运行，recoveryMiddleware 捕获不到，这里需要自行恢复
+		if r := recover(); r != nil {
+			log.Printf("[PANIC] job %s: %v\n%s", job.id, r, string(debug.Stack()))
+			result, err = nil, fmt.Errorf("internal error: %v", r)
+		}
+		job.finish(result, err)
+		status := job.Status()
+		fmt.Printf("[Job] Finished %s: %s in %dms\n", job.id, status.State, status.ElapsedMs)
+	}()
+
+	job.start()
+	result, err = job.task(WithPriority(job.ctx, job.priority), job)
+}
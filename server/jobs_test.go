@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJobManagerEvictsOldestFinished(t *testing.T) {
+	m := &JobManager{jobs: make(map[string]*Job)}
+	base := time.Now().Add(-time.Minute)
+	for i := 0; i < maxRetainedJobs+5; i++ {
+		job := &Job{id: fmt.Sprintf("done-%d", i), state: JobDone, finishedAt: base.Add(time.Duration(i) * time.Second)}
+		m.jobs[job.id] = job
+	}
+	// 进行中的任务不会被清理
+	m.jobs["running"] = &Job{id: "running", state: JobCounting}
+	m.evictLocked()
+
+	if len(m.jobs) != maxRetainedJobs {
+		t.Fatalf("retained %d jobs, want %d", len(m.jobs), maxRetainedJobs)
+	}
+	if _, ok := m.Get("running"); !ok {
+		t.Error("running job was evicted")
+	}
+	for i := 0; i < 6; i++ {
+		if _, ok := m.Get(fmt.Sprintf("done-%d", i)); ok {
+			t.Errorf("done-%d should have been evicted first", i)
+		}
+	}
+	if _, ok := m.Get(fmt.Sprintf("done-%d", maxRetainedJobs+4)); !ok {
+		t.Error("newest finished job was evicted")
+	}
+
+	m.Remove("running")
+	if _, ok := m.Get("running"); ok {
+		t.Error("Remove() left the job in place")
+	}
+}
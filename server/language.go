@@ -1,5 +1,11 @@
 package main
 
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
 // LanguageCategory 语言分类
 type LanguageCategory string
 
@@ -7,9 +13,37 @@ const (
 	CategoryProgramming   LanguageCategory = "programming"   // 编程语言
 	CategoryData          LanguageCategory = "data"          // 数据文件
 	CategoryDocumentation LanguageCategory = "documentation" // 文档文件
+	CategoryMarkup        LanguageCategory = "markup"        // 标记/模板语言
+	CategoryStyle         LanguageCategory = "style"         // 样式表
+	CategoryBuild         LanguageCategory = "build"         // 构建脚本
+	CategoryTest          LanguageCategory = "test"          // 测试代码（按路径识别）
+	CategoryLockfile      LanguageCategory = "lockfile"      // 依赖锁文件（按文件名识别）
 	CategoryOther         LanguageCategory = "other"         // 其他
 )
 
+// AllCategories 所有可配置的分类（按展示顺序）
+var AllCategories = []LanguageCategory{
+	CategoryProgramming,
+	CategoryData,
+	CategoryDocumentation,
+	CategoryMarkup,
+	CategoryStyle,
+	CategoryBuild,
+	CategoryTest,
+	CategoryLockfile,
+	CategoryOther,
+}
+
+// IsValidCategory 判断分类名是否合法
+func IsValidCategory(category LanguageCategory) bool {
+	for _, c := range AllCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
 // 数据文件语言列表
 var dataLanguages = map[string]bool{
 	"JSON":             true,
@@ -30,7 +64,8 @@ var dataLanguages = map[string]bool{
 	"Plist":            true, // Apple Property List
 	"XAML":             true,
 	"SVG":              true,
-	"Dockerfile":       true, // 配置文件
+	"XSD":              true,
+	"XML resource":     true,
 	"Docker Compose":   true,
 	"Kubernetes":       true,
 	"Terraform":        true,
@@ -45,16 +80,17 @@ var dataLanguages = map[string]bool{
 	"gitignore":        true,
 	"gitattributes":    true,
 	"npmrc":            true,
-	"Cargo Lock":       true,
-	"Cython":           true,
+	"Unity-Prefab":     true,
 }
 
 // 文档文件语言列表
 var documentationLanguages = map[string]bool{
 	"Markdown":         true,
+	"RMarkdown":        true,
 	"Plain Text":       true,
 	"Text":             true,
 	"reStructuredText": true,
+	"ReStructuredText": true, // gocloc 使用的名称
 	"AsciiDoc":         true,
 	"Org":              true, // Emacs Org mode
 	"LaTeX":            true,
@@ -73,18 +109,144 @@ var documentationLanguages = map[string]bool{
 	"NOTICE":           true,
 }
 
-// GetLanguageCategory 获取语言的分类
-func GetLanguageCategory(language string) LanguageCategory {
-	if dataLanguages[language] {
-		return CategoryData
+// 标记/模板语言列表
+var markupLanguages = map[string]bool{
+	"HTML":       true,
+	"XSLT":       true,
+	"Handlebars": true,
+	"Mustache":   true,
+	"Nunjucks":   true,
+	"Ruby HTML":  true, // ERB 模板
+	"JSP":        true,
+	"Haml":       true,
+	"Pug":        true,
+	"Twig":       true,
+	"Liquid":     true,
+}
+
+// 样式表语言列表
+var styleLanguages = map[string]bool{
+	"CSS":     true,
+	"Sass":    true, // gocloc 将 .scss 也识别为 Sass
+	"SCSS":    true,
+	"LESS":    true,
+	"Stylus":  true,
+	"PostCSS": true,
+}
+
+// 构建脚本语言列表
+var buildLanguages = map[string]bool{
+	"Makefile":       true,
+	"CMake":          true,
+	"Meson":          true,
+	"M4":             true, // configure.ac
+	"Maven":          true, // pom.xml
+	"Ant":            true, // build.xml
+	"MSBuild script": true,
+	"Starlark":       true, // Bazel
+	"BitBake":        true,
+	"Just":           true,
+	"Dockerfile":     true,
+	"Inno Setup":     true,
+	"NSIS":           true,
+	"WiX":            true,
+}
+
+// LockfileLanguage gocloc 无法识别的锁文件使用的语言名
+const LockfileLanguage = "Lockfile"
+
+// 依赖锁文件名列表
+// 这些文件由包管理器生成，行数往往远超真实代码，按文件名精确识别
+var lockfileNames = map[string]bool{
+	"package-lock.json":   true, // npm
+	"npm-shrinkwrap.json": true, // npm
+	"yarn.lock":           true, // Yarn
+	"pnpm-lock.yaml":      true, // pnpm
+	"bun.lock":            true, // Bun
+	"go.sum":              true, // Go modules
+	"Cargo.lock":          true, // Rust
+	"poetry.lock":         true, // Python Poetry
+	"Pipfile.lock":        true, // Python Pipenv
+	"pdm.lock":            true, // Python PDM
+	"uv.lock":             true, // Python uv
+	"Gemfile.lock":        true, // Ruby Bundler
+	"composer.lock":       true, // PHP Composer
+	"Podfile.lock":        true, // iOS CocoaPods
+	"Package.resolved":    true, // Swift Package Manager
+	"pubspec.lock":        true, // Dart/Flutter
+	"mix.lock":            true, // Elixir
+	"packages.lock.json":  true, // NuGet
+	"gradle.lockfile":     true, // Gradle
+	"flake.lock":          true, // Nix
+	"conan.lock":          true, // Conan
+}
+
+// IsLockfile 根据文件名判断是否是依赖锁文件
+func IsLockfile(filePath string) bool {
+	cleanPath := strings.ReplaceAll(filePath, "\\", "/")
+	return lockfileNames[path.Base(cleanPath)]
+}
+
+// DefaultTestPatterns 默认的测试文件路径规则（gitignore 风格）
+// 不含 "/" 的规则匹配任意层级的文件名，以 "/" 结尾的规则匹配任意层级的目录
+var DefaultTestPatterns = []string{
+	"*_test.go",
+	"*.test.js", "*.test.jsx", "*.test.ts", "*.test.tsx",
+	"*.spec.js", "*.spec.jsx", "*.spec.ts", "*.spec.tsx",
+	"test_*.py", "*_test.py",
+	"*Test.java", "*Tests.java", "*Test.kt",
+	"*Tests.cs", "*Test.cs",
+	"*_spec.rb", "*_test.rb",
+	"test/", "tests/", "__tests__/", "spec/",
+}
+
+// CategoryTable 语言名称到分类的映射表
+type CategoryTable map[string]LanguageCategory
+
+// DefaultCategoryTable 返回内置的语言分类表
+func DefaultCategoryTable() CategoryTable {
+	table := make(CategoryTable)
+	groups := []struct {
+		category  LanguageCategory
+		languages map[string]bool
+	}{
+		{CategoryData, dataLanguages},
+		{CategoryDocumentation, documentationLanguages},
+		{CategoryMarkup, markupLanguages},
+		{CategoryStyle, styleLanguages},
+		{CategoryBuild, buildLanguages},
+	}
+	for _, g := range groups {
+		for lang := range g.languages {
+			table[lang] = g.category
+		}
+	}
+	table[LockfileLanguage] = CategoryLockfile
+	return table
+}
+
+// BuildCategoryTable 在默认分类表的基础上应用用户覆盖
+func BuildCategoryTable(overrides map[string]LanguageCategory) CategoryTable {
+	table := DefaultCategoryTable()
+	for lang, category := range overrides {
+		table[lang] = category
 	}
-	if documentationLanguages[language] {
-		return CategoryDocumentation
+	return table
+}
+
+// Category 获取语言的分类，未登记的语言默认认为是编程语言
+func (t CategoryTable) Category(language string) LanguageCategory {
+	if category, ok := t[language]; ok {
+		return category
 	}
-	// 默认认为是编程语言
 	return CategoryProgramming
 }
 
+// GetLanguageCategory 获取语言的默认分类
+func GetLanguageCategory(language string) LanguageCategory {
+	return DefaultCategoryTable().Category(language)
+}
+
 // IsDataLanguage 判断是否是数据文件语言
 func IsDataLanguage(language string) bool {
 	return dataLanguages[language]
@@ -95,18 +257,73 @@ func IsDocumentationLanguage(language string) bool {
 	return documentationLanguages[language]
 }
 
-// ShouldIncludeLanguage 根据配置判断是否应该包含该语言
-func ShouldIncludeLanguage(language string, includeData bool, includeDoc bool) bool {
-	category := GetLanguageCategory(language)
+// FileClassifier 根据配置对文件进行分类和过滤
+type FileClassifier struct {
+	table   CategoryTable
+	tests   *PathMatcher
+	include map[LanguageCategory]bool
+}
 
-	switch category {
-	case CategoryData:
-		return includeData
-	case CategoryDocumentation:
-		return includeDoc
-	case CategoryProgramming, CategoryOther:
-		return true
-	default:
+// NewFileClassifier 根据配置创建文件分类器
+func NewFileClassifier(cfg Config) *FileClassifier {
+	testPatterns := cfg.TestPatterns
+	if testPatterns == nil {
+		testPatterns = DefaultTestPatterns
+	}
+	tests, err := NewPathMatcher(testPatterns)
+	if err != nil {
+		fmt.Printf("[Warning] Invalid test patterns, using defaults: %v\n", err)
+		tests, _ = NewPathMatcher(DefaultTestPatterns)
+	}
+
+	return &FileClassifier{
+		table: BuildCategoryTable(cfg.LanguageCategories),
+		tests: tests,
+		include: map[LanguageCategory]bool{
+			CategoryProgramming:   true,
+			CategoryOther:         true,
+			CategoryData:          cfg.IncludeDataFiles,
+			CategoryDocumentation: cfg.IncludeDocumentation,
+			CategoryMarkup:        cfg.IncludeMarkup,
+			CategoryStyle:         cfg.IncludeStyle,
+			CategoryBuild:         cfg.IncludeBuild,
+			CategoryTest:          cfg.IncludeTests,
+			CategoryLockfile:      cfg.IncludeLockfiles,
+		},
+	}
+}
+
+// Classify 获取文件的分类
+// 锁文件按文件名优先识别；测试规则只作用于编程语言文件，测试目录下的数据/文档仍按语言归类
+func (c *FileClassifier) Classify(f FileStat) LanguageCategory {
+	if IsLockfile(f.Path) {
+		return CategoryLockfile
+	}
+	category := c.table.Category(f.Language)
+	if category != CategoryProgramming {
+		return category
+	}
+	if ok, _ := c.tests.Match(f.Path); ok {
+		return CategoryTest
+	}
+	return category
+}
+
+// IncludeCategory 判断该分类是否参与统计
+func (c *FileClassifier) IncludeCategory(category LanguageCategory) bool {
+	include, ok := c.include[category]
+	if !ok {
 		return true
 	}
+	return include
+}
+
+// ShouldInclude 根据配置判断是否应该包含该文件
+func (c *FileClassifier) ShouldInclude(f FileStat) bool {
+	return c.IncludeCategory(c.Classify(f))
+}
+
+// Table 返回分类器使用的分类表
+func (c *FileClassifier) Table() CategoryTable {
+	return c.table
 }
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestIsLockfile(t *testing.T) {
+	cases := map[string]bool{
+		"package-lock.json":            true,
+		"web/yarn.lock":                true,
+		"deps\\Cargo.lock":             true,
+		"go.sum":                       true,
+		"go.mod":                       false,
+		"package.json":                 false,
+		"vcpkg-configuration.json":     false, // 手写的配置文件，不是锁文件
+		"docs/packages.lock.json.orig": false,
+	}
+	for path, want := range cases {
+		if got := IsLockfile(path); got != want {
+			t.Errorf("IsLockfile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
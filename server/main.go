@@ -8,15 +8,37 @@ import (
 )
 
 var appConfig *AppConfig
-var cache *SafeCache
+var cache Cache
+var failures *FailureCache
+var jobs *JobManager
+var workers *WorkerPool
 
 func main() {
 	appConfig = NewAppConfig()
 
-	cache = NewCache(10 * time.Minute)
+	var err error
+	cache, err = NewCacheBackend(appConfig.Get(), 10*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("GoLoc cache backend: %s\n", appConfig.Get().CacheBackend)
+	failures = NewFailureCache(10 * time.Minute)
+	jobs = NewJobManager(10 * time.Minute)
+	workers = NewWorkerPool(appConfig.Get().PoolLimits())
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/api/analyze", handleAnalyze)
+	mux.HandleFunc("/api/analyze/batch", handleAnalyzeBatch)
+	mux.HandleFunc("/api/analyze/org", handleAnalyzeOrg)
+	mux.HandleFunc("/api/jobs", handleJobs)
+	mux.HandleFunc("/api/jobs/", handleJob)
+	mux.HandleFunc("/api/tree", handleTree)
+	mux.HandleFunc("/api/files", handleFiles)
 	mux.HandleFunc("/api/config", handleConfig)
+	mux.HandleFunc("/api/categories", handleCategories)
+	mux.HandleFunc("/api/cache", handleCache)
+	mux.HandleFunc("/api/cache/entries", handleCacheEntries)
+	mux.HandleFunc("/api/cache/entry", handleCacheEntry)
 	corsHandler := corsMiddleware(mux)
 
 	finalHandler := recoveryMiddleware(corsHandler)
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	orgReposPerPage = 100
+	maxOrgRepoPages = 20 // 最多列出 2000 个仓库
+)
+
+// OwnerRepo GitHub API 返回的仓库信息（只保留筛选需要的字段），GitLab 项目转换为同样的结构
+type OwnerRepo struct {
+	Name          string   `json:"name"`
+	HTMLURL       string   `json:"html_url"`
+	DefaultBranch string   `json:"default_branch"`
+	Fork          bool     `json:"fork"`
+	Archived      bool     `json:"archived"`
+	Topics        []string `json:"topics"`
+}
+
+// OwnerRepoFilter 组织/用户仓库的筛选条件
+type OwnerRepoFilter struct {
+	includeForks    bool
+	includeArchived bool
+	topics          map[string]bool
+	name            *regexp.Regexp
+	excludeName     *regexp.Regexp
+}
+
+// NewOwnerRepoFilter 根据请求创建筛选条件，正则无效时返回错误
+func NewOwnerRepoFilter(req OrgAnalyzeRequest) (*OwnerRepoFilter, error) {
+	f := &OwnerRepoFilter{includeForks: req.IncludeForks, includeArchived: req.IncludeArchived}
+	if len(req.Topics) > 0 {
+		f.topics = make(map[string]bool, len(req.Topics))
+		for _, topic := range req.Topics {
+			f.topics[strings.ToLower(strings.TrimSpace(topic))] = true
+		}
+	}
+	var err error
+	if req.NameRegex != "" {
+		if f.name, err = regexp.Compile(req.NameRegex); err != nil {
+			return nil, fmt.Errorf("name_regex: %v", err)
+		}
+	}
+	if req.ExcludeNameRegex != "" {
+		if f.excludeName, err = regexp.Compile(req.ExcludeNameRegex); err != nil {
+			return nil, fmt.Errorf("exclude_name_regex: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// Match 仓库是否满足所有筛选条件，topics 命中任意一个即可
+func (f *OwnerRepoFilter) Match(repo OwnerRepo) bool {
+	if repo.Fork && !f.includeForks {
+		return false
+	}
+	if repo.Archived && !f.includeArchived {
+		return false
+	}
+	if f.name != nil && !f.name.MatchString(repo.Name) {
+		return false
+	}
+	if f.excludeName != nil && f.excludeName.MatchString(repo.Name) {
+		return false
+	}
+	if f.topics != nil {
+		for _, topic := range repo.Topics {
+			if f.topics[strings.ToLower(topic)] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// isGitLabOwner 判断传入的是否为 GitLab 群组地址
+func isGitLabOwner(owner string) bool {
+	owner = strings.TrimSpace(owner)
+	for _, prefix := range []string{"https://", "http://"} {
+		owner = strings.TrimPrefix(owner, prefix)
+	}
+	host, _, _ := strings.Cut(owner, "/")
+	return host == "gitlab.com" || strings.HasPrefix(host, "gitlab.")
+}
+
+// gitlabAPIBase gitlab.com 的 API 地址，测试中替换为本地服务
+var gitlabAPIBase = "https://gitlab.com/api/v4"
+
+// gitlabOwner GitLab 群组（或用户）地址解析出的 API 地址和完整路径
+type gitlabOwner struct {
+	apiBase   string
+	path      string // 群组的完整路径，如 my-group/sub
+	gitlabCom bool   // 是否为 gitlab.com，只有 gitlab.com 使用 GITLAB_TOKEN
+}
+
+// parseGitLabOwner 解析 GitLab 群组地址，自建实例的 API 地址为 {scheme}://{host}/api/v4
+func parseGitLabOwner(owner string) gitlabOwner {
+	owner = strings.TrimSpace(owner)
+	scheme := "https"
+	if strings.HasPrefix(owner, "http://") {
+		scheme = "http"
+	}
+	for _, prefix := range []string{"https://", "http://"} {
+		owner = strings.TrimPrefix(owner, prefix)
+	}
+	host, path, _ := strings.Cut(owner, "/")
+	// 旧式的 /groups/ 前缀和群组页面的子页面（如 /-/shared）不属于群组路径
+	path, _, _ = strings.Cut(strings.TrimPrefix(path, "groups/"), "/-/")
+	result := gitlabOwner{path: strings.Trim(path, "/")}
+	if host == "gitlab.com" {
+		result.apiBase = gitlabAPIBase
+		result.gitlabCom = true
+	} else {
+		result.apiBase = scheme + "://" + host + "/api/v4"
+	}
+	return result
+}
+
+// normalizeOwner 支持直接传入组织/用户名或其 GitHub 主页地址
+func normalizeOwner(owner string) string {
+	owner = strings.TrimSpace(owner)
+	for _, prefix := range []string{"https://", "http://", "github.com/", "www.github.com/"} {
+		owner = strings.TrimPrefix(owner, prefix)
+	}
+	return strings.Trim(owner, "/")
+}
+
+// ListOwnerRepos 列出 GitHub 组织或用户的公开仓库（使用 GITHUB_TOKEN 时包含有权访问的私有仓库）
+// 先按组织查询，不存在时按用户查询
+func ListOwnerRepos(ctx context.Context, owner string, token string) ([]OwnerRepo, error) {
+	escaped := url.PathEscape(owner)
+	repos, err := listGithubRepos(ctx, fmt.Sprintf("%s/orgs/%s/repos?type=all", githubAPIBase, escaped), token)
+	if errorClass(err) == ErrorNotFound {
+		repos, err = listGithubRepos(ctx, fmt.Sprintf("%s/users/%s/repos?type=owner", githubAPIBase, escaped), token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[API] Listed %d repos of %s\n", len(repos), owner)
+	return repos, nil
+}
+
+// listGithubRepos 逐页读取仓库列表，最多 maxOrgRepoPages 页
+func listGithubRepos(ctx context.Context, apiURL string, token string) ([]OwnerRepo, error) {
+	var repos []OwnerRepo
+	for page := 1; page <= maxOrgRepoPages; page++ {
+		resp, err := githubGet(ctx, fmt.Sprintf("%s&per_page=%d&page=%d", apiURL, orgReposPerPage, page), token)
+		if err != nil {
+			return nil, err
+		}
+		if err := githubStatusError(resp, "organization or user not found"); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		var batch []OwnerRepo
+		err = json.NewDecoder(resp.Body).Decode(&batch)
+		resp.Body.Close()
+		if err != nil {
+			return nil, newAnalysisError(ErrorUpstream, "failed to decode api response: %v", err)
+		}
+		repos = append(repos, batch...)
+		if len(batch) < orgReposPerPage {
+			break
+		}
+	}
+	return repos, nil
+}
+
+// gitlabProject GitLab API 返回的项目信息（只保留筛选需要的字段）
+type gitlabProject struct {
+	Path              string    `json:"path"`
+	WebURL            string    `json:"web_url"`
+	DefaultBranch     string    `json:"default_branch"`
+	Archived          bool      `json:"archived"`
+	Topics            []string  `json:"topics"`
+	ForkedFromProject *struct{} `json:"forked_from_project"`
+}
+
+// ListGitLabProjects 列出 GitLab 群组及其子群组的项目，群组不存在时按用户查询
+// 归档和单个 topic 的筛选交给 API，其余条件仍由 OwnerRepoFilter 判断
+func ListGitLabProjects(ctx context.Context, req OrgAnalyzeRequest, token string) ([]OwnerRepo, error) {
+	owner := parseGitLabOwner(req.Owner)
+	if !owner.gitlabCom {
+		token = ""
+	}
+	query := url.Values{"with_shared": {"false"}}
+	if !req.IncludeArchived {
+		query.Set("archived", "false")
+	}
+	if len(req.Topics) == 1 {
+		query.Set("topic", strings.TrimSpace(req.Topics[0]))
+	}
+
+	// 完整路径中的 / 需要编码为 %2F
+	escaped := strings.ReplaceAll(url.PathEscape(owner.path), "/", "%2F")
+	groupQuery := url.Values{"include_subgroups": {"true"}}
+	for k, v := range query {
+		groupQuery[k] = v
+	}
+	repos, err := listGitLabProjects(ctx, fmt.Sprintf("%s/groups/%s/projects?%s", owner.apiBase, escaped, groupQuery.Encode()), token)
+	if errorClass(err) == ErrorNotFound && !strings.Contains(owner.path, "/") {
+		query.Del("with_shared")
+		repos, err = listGitLabProjects(ctx, fmt.Sprintf("%s/users/%s/projects?%s", owner.apiBase, escaped, query.Encode()), token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[API] Listed %d projects of %s\n", len(repos), normalizeOwner(req.Owner))
+	return repos, nil
+}
+
+// listGitLabProjects 逐页读取项目列表，最多 maxOrgRepoPages 页
+func listGitLabProjects(ctx context.Context, apiURL string, token string) ([]OwnerRepo, error) {
+	var repos []OwnerRepo
+	for page := 1; page <= maxOrgRepoPages; page++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s&per_page=%d&page=%d", apiURL, orgReposPerPage, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("PRIVATE-TOKEN", token)
+		}
+		resp, err := doAPIRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if err := apiStatusError(resp, "gitlab", "group or user not found"); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		var batch []gitlabProject
+		err = json.NewDecoder(resp.Body).Decode(&batch)
+		resp.Body.Close()
+		if err != nil {
+			return nil, newAnalysisError(ErrorUpstream, "failed to decode api response: %v", err)
+		}
+		for _, project := range batch {
+			repos = append(repos, OwnerRepo{
+				Name:          project.Path,
+				HTMLURL:       project.WebURL,
+				DefaultBranch: project.DefaultBranch,
+				Fork:          project.ForkedFromProject != nil,
+				Archived:      project.Archived,
+				Topics:        project.Topics,
+			})
+		}
+		if len(batch) < orgReposPerPage {
+			break
+		}
+	}
+	return repos, nil
+}
+
+// AnalyzeOwner 列出组织/用户（或 GitLab 群组）的仓库，按条件筛选后分析各仓库的默认分支并汇总，onRepoDone 见 AnalyzeBatch
+func AnalyzeOwner(ctx context.Context, req OrgAnalyzeRequest, filter *OwnerRepoFilter, cfg Config, onRepoDone func(done, total int)) (*OrgResult, error) {
+	owner := normalizeOwner(req.Owner)
+	var repos []OwnerRepo
+	var err error
+	if isGitLabOwner(req.Owner) {
+		repos, err = ListGitLabProjects(ctx, req, cfg.GitlabToken)
+	} else {
+		repos, err = ListOwnerRepos(ctx, owner, cfg.GithubToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &OrgResult{Owner: owner, Listed: len(repos)}
+	var targets []BatchTarget
+	for _, repo := range repos {
+		if !filter.Match(repo) || ValidateRepoURL(repo.HTMLURL) != nil {
+			continue
+		}
+		if len(targets) == maxBatchRepos {
+			result.Truncated = true
+			break
+		}
+		targets = append(targets, BatchTarget{RepoURL: repo.HTMLURL, Branch: repo.DefaultBranch})
+	}
+	result.Matched = len(targets)
+	fmt.Printf("[Org] %s: %d of %d repos matched\n", owner, result.Matched, result.Listed)
+
+	result.BatchResult = AnalyzeBatch(ctx, targets, cfg, onRepoDone)
+	return result, nil
+}
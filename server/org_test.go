@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestIsGitLabOwner(t *testing.T) {
+	tests := []struct {
+		owner string
+		want  bool
+	}{
+		{"https://gitlab.com/my-group", true},
+		{"gitlab.com/my-group/sub", true},
+		{"https://gitlab.example.com/team", true},
+		{"my-org", false},
+		{"https://github.com/my-org", false},
+		{"gitlab", false},
+	}
+	for _, tt := range tests {
+		if got := isGitLabOwner(tt.owner); got != tt.want {
+			t.Errorf("isGitLabOwner(%q) = %v, want %v", tt.owner, got, tt.want)
+		}
+	}
+}
+
+func TestOwnerRepoFilter(t *testing.T) {
+	f, err := NewOwnerRepoFilter(OrgAnalyzeRequest{Topics: []string{"Backend"}, ExcludeNameRegex: "^sandbox-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		repo OwnerRepo
+		want bool
+	}{
+		{OwnerRepo{Name: "api", Topics: []string{"backend"}}, true},
+		{OwnerRepo{Name: "web", Topics: []string{"frontend"}}, false},
+		{OwnerRepo{Name: "sandbox-api", Topics: []string{"backend"}}, false},
+		{OwnerRepo{Name: "api", Topics: []string{"backend"}, Fork: true}, false},
+		{OwnerRepo{Name: "api", Topics: []string{"backend"}, Archived: true}, false},
+	}
+	for _, tt := range tests {
+		if got := f.Match(tt.repo); got != tt.want {
+			t.Errorf("Match(%+v) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+
+	if _, err := NewOwnerRepoFilter(OrgAnalyzeRequest{NameRegex: "("}); err == nil {
+		t.Error("invalid name_regex accepted")
+	}
+}
+
+func TestParseGitLabOwner(t *testing.T) {
+	tests := []struct {
+		owner string
+		want  gitlabOwner
+	}{
+		{"https://gitlab.com/my-group", gitlabOwner{gitlabAPIBase, "my-group", true}},
+		{"gitlab.com/my-group/sub/", gitlabOwner{gitlabAPIBase, "my-group/sub", true}},
+		{"https://gitlab.com/groups/my-group/-/shared", gitlabOwner{gitlabAPIBase, "my-group", true}},
+		// 自建实例不使用 GITLAB_TOKEN
+		{"http://gitlab.example.com/team", gitlabOwner{"http://gitlab.example.com/api/v4", "team", false}},
+		{"gitlab.example.com", gitlabOwner{"https://gitlab.example.com/api/v4", "", false}},
+	}
+	for _, tt := range tests {
+		if got := parseGitLabOwner(tt.owner); got != tt.want {
+			t.Errorf("parseGitLabOwner(%q) = %+v, want %+v", tt.owner, got, tt.want)
+		}
+	}
+}
+
+// useFakeGitLab 本地的 GitLab API，handler 处理各请求，测试结束后恢复 gitlabAPIBase
+func useFakeGitLab(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	saved := gitlabAPIBase
+	gitlabAPIBase = server.URL
+	t.Cleanup(func() {
+		server.Close()
+		gitlabAPIBase = saved
+	})
+}
+
+func TestListGitLabProjects(t *testing.T) {
+	var requests []string
+	useFakeGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawPath+"?"+r.URL.RawQuery)
+		if r.Header.Get("PRIVATE-TOKEN") != "glpat" {
+			t.Errorf("PRIVATE-TOKEN = %q, want the configured token", r.Header.Get("PRIVATE-TOKEN"))
+		}
+		// 第一页填满时继续读取下一页
+		var projects []map[string]interface{}
+		if r.URL.Query().Get("page") == "1" {
+			for i := 0; i < orgReposPerPage-1; i++ {
+				projects = append(projects, map[string]interface{}{"path": "p" + strconv.Itoa(i), "web_url": "https://gitlab.com/my-group/p" + strconv.Itoa(i)})
+			}
+			projects = append(projects, map[string]interface{}{
+				"path": "fork", "web_url": "https://gitlab.com/my-group/sub/fork", "default_branch": "main",
+				"forked_from_project": map[string]interface{}{"id": 1},
+			})
+		} else {
+			projects = append(projects, map[string]interface{}{
+				"path": "api", "web_url": "https://gitlab.com/my-group/sub/api", "default_branch": "develop",
+				"archived": true, "topics": []string{"backend"}, "forked_from_project": nil,
+			})
+		}
+		json.NewEncoder(w).Encode(projects)
+	})
+
+	req := OrgAnalyzeRequest{Owner: "https://gitlab.com/my-group/sub", Topics: []string{"backend"}}
+	repos, err := ListGitLabProjects(context.Background(), req, "glpat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/groups/my-group%2Fsub/projects?archived=false&include_subgroups=true&topic=backend&with_shared=false&per_page=100&page=1",
+		"/groups/my-group%2Fsub/projects?archived=false&include_subgroups=true&topic=backend&with_shared=false&per_page=100&page=2",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests =\n%v\nwant\n%v", requests, want)
+	}
+	if len(repos) != orgReposPerPage+1 {
+		t.Fatalf("listed %d projects, want %d", len(repos), orgReposPerPage+1)
+	}
+	if fork := repos[orgReposPerPage-1]; fork.Name != "fork" || !fork.Fork {
+		t.Errorf("forked project = %+v", fork)
+	}
+	wantLast := OwnerRepo{Name: "api", HTMLURL: "https://gitlab.com/my-group/sub/api", DefaultBranch: "develop", Archived: true, Topics: []string{"backend"}}
+	if last := repos[orgReposPerPage]; !reflect.DeepEqual(last, wantLast) {
+		t.Errorf("project = %+v, want %+v", last, wantLast)
+	}
+}
+
+func TestListGitLabProjectsUserFallback(t *testing.T) {
+	var paths []string
+	useFakeGitLab(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path != "/users/someone/projects" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("archived") != "" {
+			t.Errorf("archived filter sent although archived projects are included: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"path": "dotfiles", "web_url": "https://gitlab.com/someone/dotfiles"}]`)
+	})
+
+	repos, err := ListGitLabProjects(context.Background(), OrgAnalyzeRequest{Owner: "gitlab.com/someone", IncludeArchived: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 || repos[0].Name != "dotfiles" {
+		t.Errorf("repos = %+v", repos)
+	}
+	if want := []string{"/groups/someone/projects", "/users/someone/projects"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+
+	// 子群组路径不能是用户名，不再按用户查询
+	paths = nil
+	_, err = ListGitLabProjects(context.Background(), OrgAnalyzeRequest{Owner: "gitlab.com/missing/sub"}, "")
+	if errorClass(err) != ErrorNotFound || len(paths) != 1 {
+		t.Errorf("err = %v after %v, want not_found from the group lookup only", err, paths)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Priority 分析的优先级
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive" // 扩展等交互式请求，用户在等待结果
+	PriorityBatch       Priority = "batch"       // 批量/组织级分析
+	PriorityBackground  Priority = "background"  // 缓存的后台刷新
+)
+
+// priorities 按优先级从高到低排列
+var priorities = []Priority{PriorityInteractive, PriorityBatch, PriorityBackground}
+
+// priorityWeights 各优先级都有分析在排队时的调度比例：每 7 次调度中交互式 4 次、批量 2 次、后台 1 次，
+// 低优先级的分析不会被饿死
+var priorityWeights = map[Priority]int{
+	PriorityInteractive: 4,
+	PriorityBatch:       2,
+	PriorityBackground:  1,
+}
+
+// ParsePriority 解析请求中的优先级，为空时为交互式
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityInteractive, nil
+	}
+	for _, p := range priorities {
+		if Priority(s) == p {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("invalid priority %q, expected interactive, batch or background", s)
+}
+
+// ParseBulkPriority 解析批量和组织级分析的优先级，为空时为 batch
+// 这类请求一次包含大量仓库，不允许以交互式优先级排队，传入 interactive 时按 batch 处理
+func ParseBulkPriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityBatch, nil
+	}
+	p, err := ParsePriority(s)
+	if err != nil {
+		return "", err
+	}
+	if p == PriorityInteractive {
+		fmt.Println("[Pool] Ignoring interactive priority for bulk analysis, using batch")
+		return PriorityBatch, nil
+	}
+	return p, nil
+}
+
+// rank 数值越小优先级越高
+func (p Priority) rank() int {
+	for i, q := range priorities {
+		if p == q {
+			return i
+		}
+	}
+	return len(priorities)
+}
+
+type priorityKey struct{}
+
+// WithPriority 在 ctx 中记录分析的优先级，LoadRepoStats 发起的分析按此排队
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// withPriorityFunc 在 ctx 中记录可变的优先级，每次读取时调用 fn 取当前值
+// 用于合并的分析：更高优先级的请求加入后，尚未排队的分析按提升后的优先级排队
+func withPriorityFunc(ctx context.Context, fn func() Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, fn)
+}
+
+// priorityFrom 读取 ctx 中的优先级，未设置时为交互式
+func priorityFrom(ctx context.Context) Priority {
+	switch p := ctx.Value(priorityKey{}).(type) {
+	case Priority:
+		return p
+	case func() Priority:
+		return p()
+	}
+	return PriorityInteractive
+}
+
+// PoolLimits 分析槽位的限制
+type PoolLimits struct {
+	Workers     int              // 同时进行的分析总数
+	QueueSize   int              // 每个优先级最多排队的分析数
+	ClassLimits map[Priority]int // 各优先级同时进行的分析数，未设置或不大于 0 时只受总数限制
+}
+
+// poolTicket 排队中的分析
+type poolTicket struct {
+	ready   chan struct{} // 分配到执行槽位时关闭
+	tracker *Tracker
+	class   *poolClass // 所在（或占用槽位）的优先级，提升优先级后随之改变
+}
+
+// poolClass 一个优先级的队列和占用情况
+type poolClass struct {
+	priority Priority
+	limit    int
+	running  int
+	queue    []*poolTicket
+	credit   int // 本轮剩余的调度次数
+}
+
+// WorkerPool 限制同时进行的克隆/统计数量，超出的分析按优先级排队，队列满时直接拒绝
+// 每个优先级有各自的并发上限，空闲槽位按 priorityWeights 加权轮流分配给各优先级的队首
+// 合并后的同一次分析只占一个槽位
+type WorkerPool struct {
+	mu        sync.Mutex
+	workers   int
+	queueSize int
+	running   int
+	classes   map[Priority]*poolClass
+}
+
+func NewWorkerPool(limits PoolLimits) *WorkerPool {
+	p := &WorkerPool{
+		workers:   max(limits.Workers, 1),
+		queueSize: max(limits.QueueSize, 0),
+		classes:   make(map[Priority]*poolClass),
+	}
+	for _, priority := range priorities {
+		limit := limits.ClassLimits[priority]
+		if limit <= 0 || limit > p.workers {
+			limit = p.workers
+		}
+		p.classes[priority] = &poolClass{priority: priority, limit: limit, credit: priorityWeights[priority]}
+	}
+	return p
+}
+
+// Acquire 获取执行槽位，优先级取自 ctx（见 WithPriority）
+// 需要排队时通过 tracker 上报在同一优先级队列中的位置（从 1 开始）
+// 队列已满时返回 busy 错误；ctx 结束时放弃排队并返回 ctx.Err()
+// 成功后必须调用返回的 release 释放槽位
+func (p *WorkerPool) Acquire(ctx context.Context, tracker *Tracker) (release func(), err error) {
+	p.mu.Lock()
+	// 持有锁时读取优先级：与 Promote 互斥，读取之后的提升一定能在队列中找到这次排队
+	class := p.classes[priorityFrom(ctx)]
+	ticket := &poolTicket{ready: make(chan struct{}), tracker: tracker, class: class}
+	if p.running < p.workers && class.running < class.limit && len(class.queue) == 0 {
+		p.running++
+		class.running++
+		p.mu.Unlock()
+		return p.releaseFunc(ticket), nil
+	}
+	if len(class.queue) >= p.queueSize {
+		p.mu.Unlock()
+		return nil, newAnalysisError(ErrorBusy, "server busy: %d %s analyses queued, try again later", p.queueSize, class.priority)
+	}
+	class.queue = append(class.queue, ticket)
+	position := len(class.queue)
+	p.mu.Unlock()
+
+	fmt.Printf("[Pool] Queued %s analysis at position %d\n", class.priority, position)
+	tracker.Report(ProgressEvent{Stage: StageQueued, Position: position})
+
+	select {
+	case <-ticket.ready:
+		return p.releaseFunc(ticket), nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		if p.remove(ticket) {
+			queue := append([]*poolTicket{}, ticket.class.queue...)
+			p.mu.Unlock()
+			reportPositions(queue)
+			return nil, ctx.Err()
+		}
+		p.mu.Unlock()
+		// 取消的同时已分配到槽位，归还给下一个排队者
+		p.release(ticket)
+		return nil, ctx.Err()
+	}
+}
+
+// Promote 将 tracker 对应的排队中的分析提升到更高的优先级（排在该优先级队列的末尾）
+// 用于交互式请求加入了排队中的批量或后台分析，已在执行、优先级不低于 priority 或目标队列已满时不做任何事
+func (p *WorkerPool) Promote(tracker *Tracker, priority Priority) {
+	target := p.classes[priority]
+
+	p.mu.Lock()
+	var ticket *poolTicket
+	for _, class := range p.classes {
+		for _, t := range class.queue {
+			if t.tracker == tracker {
+				ticket = t
+			}
+		}
+	}
+	if ticket == nil || ticket.class.priority.rank() <= priority.rank() {
+		p.mu.Unlock()
+		return
+	}
+	// 目标队列已满时留在原队列，提升不能绕过各优先级的队列上限
+	if len(target.queue) >= p.queueSize {
+		from := ticket.class.priority
+		p.mu.Unlock()
+		fmt.Printf("[Pool] Not promoting queued %s analysis: %s queue is full\n", from, priority)
+		return
+	}
+	from := ticket.class
+	p.remove(ticket)
+	ticket.class = target
+	target.queue = append(target.queue, ticket)
+	fmt.Printf("[Pool] Promoted queued analysis from %s to %s\n", from.priority, priority)
+	queues := p.dispatch()
+	queues = append(queues, append([]*poolTicket{}, from.queue...), append([]*poolTicket{}, target.queue...))
+	p.mu.Unlock()
+
+	for _, queue := range queues {
+		reportPositions(queue)
+	}
+}
+
+func (p *WorkerPool) releaseFunc(ticket *poolTicket) func() {
+	var once sync.Once
+	return func() { once.Do(func() { p.release(ticket) }) }
+}
+
+// release 归还槽位并调度排队的分析
+func (p *WorkerPool) release(ticket *poolTicket) {
+	p.mu.Lock()
+	p.running--
+	ticket.class.running--
+	queues := p.dispatch()
+	p.mu.Unlock()
+
+	for _, queue := range queues {
+		reportPositions(queue)
+	}
+}
+
+// dispatch 将空闲槽位分配给排队的分析，返回队列有变化的优先级的排队快照，调用方需持有锁
+func (p *WorkerPool) dispatch() [][]*poolTicket {
+	var changed []*poolClass
+	for p.running < p.workers {
+		class := p.next()
+		if class == nil {
+			break
+		}
+		ticket := class.queue[0]
+		class.queue = class.queue[1:]
+		class.running++
+		p.running++
+		close(ticket.ready)
+		changed = append(changed, class)
+	}
+
+	var queues [][]*poolTicket
+	for _, class := range changed {
+		queues = append(queues, append([]*poolTicket{}, class.queue...))
+	}
+	return queues
+}
+
+// next 按加权轮询选出下一个获得槽位的优先级：有排队且未达到并发上限的优先级中，
+// 选本轮仍有调度次数的最高优先级；都已用完时开始新一轮
+func (p *WorkerPool) next() *poolClass {
+	var eligible []*poolClass
+	for _, priority := range priorities {
+		class := p.classes[priority]
+		if len(class.queue) > 0 && class.running < class.limit {
+			eligible = append(eligible, class)
+		}
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+	for _, class := range eligible {
+		if class.credit > 0 {
+			class.credit--
+			return class
+		}
+	}
+	for _, class := range p.classes {
+		class.credit = priorityWeights[class.priority]
+	}
+	eligible[0].credit--
+	return eligible[0]
+}
+
+// remove 从所在队列中移除，调用方需持有锁；已被分配槽位时返回 false
+func (p *WorkerPool) remove(ticket *poolTicket) bool {
+	class := ticket.class
+	for i, t := range class.queue {
+		if t == ticket {
+			class.queue = append(class.queue[:i], class.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// reportPositions 向排队中的分析上报最新位置
+func reportPositions(queue []*poolTicket) {
+	for i, ticket := range queue {
+		ticket.tracker.Report(ProgressEvent{Stage: StageQueued, Position: i + 1})
+	}
+}
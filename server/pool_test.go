@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type poolGrant struct {
+	priority Priority // 排队时的优先级
+	tracker  *Tracker
+	release  func()
+}
+
+// queueLen 返回某个优先级当前排队的分析数
+func queueLen(p *WorkerPool, priority Priority) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.classes[priority].queue)
+}
+
+// enqueue 在后台排队一次分析，返回前确认已进入队列，保证同一优先级内的顺序
+func enqueue(t *testing.T, p *WorkerPool, priority Priority, tracker *Tracker, grants chan<- poolGrant) {
+	t.Helper()
+	before := queueLen(p, priority)
+	go func() {
+		release, err := p.Acquire(WithPriority(context.Background(), priority), tracker)
+		if err != nil {
+			t.Errorf("Acquire(%s): %v", priority, err)
+			return
+		}
+		grants <- poolGrant{priority, tracker, release}
+	}()
+	waitFor(t, func() bool { return queueLen(p, priority) == before+1 })
+}
+
+var priorityLetters = map[Priority]string{
+	PriorityInteractive: "I",
+	PriorityBatch:       "B",
+	PriorityBackground:  "G",
+}
+
+// drain 依次释放槽位，返回排队的分析获得槽位的顺序
+func drain(release func(), grants <-chan poolGrant, n int) string {
+	var order strings.Builder
+	for i := 0; i < n; i++ {
+		release()
+		g := <-grants
+		order.WriteString(priorityLetters[g.priority])
+		release = g.release
+	}
+	release()
+	return order.String()
+}
+
+func TestWorkerPoolWeightedRoundRobin(t *testing.T) {
+	p := NewWorkerPool(PoolLimits{Workers: 1, QueueSize: 10})
+	release, err := p.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grants := make(chan poolGrant)
+	for _, c := range []struct {
+		priority Priority
+		n        int
+	}{{PriorityBackground, 3}, {PriorityBatch, 5}, {PriorityInteractive, 8}} {
+		for i := 0; i < c.n; i++ {
+			enqueue(t, p, c.priority, nil, grants)
+		}
+	}
+
+	// 每 7 次调度中交互式 4 次、批量 2 次、后台 1 次，交互式排空后其余优先级继续轮转
+	if got, want := drain(release, grants, 16), "IIIIBBGIIIIBBGBG"; got != want {
+		t.Errorf("dispatch order = %s, want %s", got, want)
+	}
+}
+
+func TestWorkerPoolClassLimits(t *testing.T) {
+	p := NewWorkerPool(PoolLimits{Workers: 2, QueueSize: 10, ClassLimits: map[Priority]int{PriorityBatch: 1}})
+	batch := WithPriority(context.Background(), PriorityBatch)
+
+	releaseBatch, err := p.Acquire(batch, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 批量已达上限，即使有空闲槽位也要排队
+	grants := make(chan poolGrant, 1)
+	enqueue(t, p, PriorityBatch, nil, grants)
+
+	// 空闲槽位仍可分配给交互式
+	releaseInteractive, err := p.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseInteractive()
+	if queueLen(p, PriorityBatch) != 1 {
+		t.Fatal("queued batch analysis started above its class limit")
+	}
+
+	releaseBatch()
+	g := <-grants
+	if g.priority != PriorityBatch {
+		t.Errorf("granted %s, want batch", g.priority)
+	}
+	g.release()
+}
+
+func TestWorkerPoolBusy(t *testing.T) {
+	p := NewWorkerPool(PoolLimits{Workers: 1, QueueSize: 1})
+	release, err := p.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants := make(chan poolGrant, 1)
+	enqueue(t, p, PriorityInteractive, nil, grants)
+
+	_, err = p.Acquire(context.Background(), nil)
+	var analysisErr *AnalysisError
+	if !errors.As(err, &analysisErr) || analysisErr.Class != ErrorBusy {
+		t.Fatalf("Acquire on a full queue: err = %v, want busy", err)
+	}
+	// 队列按优先级分别计数，其他优先级不受影响
+	enqueue(t, p, PriorityBackground, nil, grants)
+
+	// 取消排队后让出位置
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Acquire(WithPriority(ctx, PriorityBatch), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire with canceled ctx: err = %v, want context.Canceled", err)
+	}
+	if queueLen(p, PriorityBatch) != 0 {
+		t.Error("canceled analysis left in the queue")
+	}
+
+	release()
+	(<-grants).release()
+	(<-grants).release()
+}
+
+func TestWorkerPoolPromote(t *testing.T) {
+	p := NewWorkerPool(PoolLimits{Workers: 1, QueueSize: 10})
+	release, err := p.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grants := make(chan poolGrant)
+	tracker := NewTracker()
+	var mu sync.Mutex
+	var positions []int
+	tracker.Observe(func(e ProgressEvent) {
+		mu.Lock()
+		positions = append(positions, e.Position)
+		mu.Unlock()
+	})
+
+	enqueue(t, p, PriorityBatch, nil, grants)
+	enqueue(t, p, PriorityBatch, nil, grants)
+	enqueue(t, p, PriorityBackground, tracker, grants)
+
+	p.Promote(tracker, PriorityInteractive)
+	if queueLen(p, PriorityBackground) != 0 || queueLen(p, PriorityInteractive) != 1 {
+		t.Fatal("promoted analysis not moved to the interactive queue")
+	}
+	mu.Lock()
+	if last := positions[len(positions)-1]; last != 1 {
+		t.Errorf("position after promotion = %d, want 1", last)
+	}
+	mu.Unlock()
+	// 不会降级
+	p.Promote(tracker, PriorityBackground)
+	if queueLen(p, PriorityInteractive) != 1 {
+		t.Error("Promote demoted a queued analysis")
+	}
+
+	// 提升后先于排在前面的批量分析获得槽位
+	release()
+	g := <-grants
+	if g.tracker != tracker {
+		t.Errorf("first grant went to a queued %s analysis, want the promoted one", g.priority)
+	}
+	if got, want := drain(g.release, grants, 2), "BB"; got != want {
+		t.Errorf("dispatch order = %s, want %s", got, want)
+	}
+}
+
+// runningCount 返回正在执行的分析数
+func runningCount(p *WorkerPool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+func TestWorkerPoolBoundsConcurrency(t *testing.T) {
+	cfg := NewAppConfig().Get()
+	cfg.MaxConcurrentAnalyses = 2
+	cfg.AnalysisQueueSize = 1
+	p := NewWorkerPool(cfg.PoolLimits())
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := p.Acquire(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+
+	// 达到上限后排队等待
+	grants := make(chan poolGrant, 1)
+	enqueue(t, p, PriorityInteractive, nil, grants)
+	select {
+	case <-grants:
+		t.Fatal("Acquire returned above MaxConcurrentAnalyses")
+	default:
+	}
+
+	// 队列已满时直接拒绝
+	_, err := p.Acquire(context.Background(), nil)
+	var analysisErr *AnalysisError
+	if !errors.As(err, &analysisErr) || analysisErr.Class != ErrorBusy || analysisErr.Code() != 503 {
+		t.Fatalf("Acquire on a full queue: err = %v, want busy (503)", err)
+	}
+
+	// 释放一个槽位后排队的分析开始执行，重复释放不会多让出槽位
+	releases[0]()
+	releases[0]()
+	g := <-grants
+	if n := runningCount(p); n != 2 {
+		t.Errorf("running = %d, want 2", n)
+	}
+	g.release()
+	releases[1]()
+	if n := runningCount(p); n != 0 {
+		t.Errorf("running = %d after all releases, want 0", n)
+	}
+}
+
+func TestWorkerPoolCancelWhileQueued(t *testing.T) {
+	p := NewWorkerPool(PoolLimits{Workers: 1, QueueSize: 1})
+	release, err := p.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.Acquire(ctx, nil)
+		errs <- err
+	}()
+	waitFor(t, func() bool { return queueLen(p, PriorityInteractive) == 1 })
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Acquire() = %v, want context.Canceled", err)
+	}
+
+	// 取消的分析让出队列位置，也不会占用之后释放的槽位
+	grants := make(chan poolGrant, 1)
+	enqueue(t, p, PriorityInteractive, nil, grants)
+	release()
+	g := <-grants
+	if n := runningCount(p); n != 1 {
+		t.Errorf("running = %d, want 1", n)
+	}
+	g.release()
+}
+
+// 分析进行中被取消时归还槽位
+func TestAnalysisReleasesSlotOnCancel(t *testing.T) {
+	cfg := NewAppConfig().Get()
+	cfg.MaxConcurrentAnalyses = 1
+	api := useFakeGithub(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := LoadRepoStats(ctx, "http://127.0.0.1:1/pool/cancel", "main", cfg, nil)
+		errs <- err
+	}()
+	waitFor(t, func() bool { return api.requests.Load() == 1 })
+	if n := runningCount(workers); n != 1 {
+		t.Fatalf("running = %d during analysis, want 1", n)
+	}
+
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("LoadRepoStats() = %v, want context.Canceled", err)
+	}
+	waitFor(t, func() bool { return runningCount(workers) == 0 })
+	release, err := workers.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("slot not available after cancel: %v", err)
+	}
+	release()
+}
+
+func TestWorkerPoolPromoteRespectsQueueSize(t *testing.T) {
+	p := NewWorkerPool(PoolLimits{Workers: 1, QueueSize: 1})
+	release, err := p.Acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grants := make(chan poolGrant, 2)
+	tracker := NewTracker()
+	enqueue(t, p, PriorityInteractive, nil, grants)
+	enqueue(t, p, PriorityBackground, tracker, grants)
+
+	// 交互式队列已满，提升不生效
+	p.Promote(tracker, PriorityInteractive)
+	if queueLen(p, PriorityInteractive) != 1 || queueLen(p, PriorityBackground) != 1 {
+		t.Fatalf("queues after promotion = %d interactive, %d background, want 1 and 1",
+			queueLen(p, PriorityInteractive), queueLen(p, PriorityBackground))
+	}
+
+	// 队列有空位后可以提升
+	release()
+	g := <-grants
+	p.Promote(tracker, PriorityInteractive)
+	if queueLen(p, PriorityInteractive) != 1 || queueLen(p, PriorityBackground) != 0 {
+		t.Error("promotion not applied once the interactive queue had room")
+	}
+	g.release()
+	if g = <-grants; g.tracker != tracker {
+		t.Error("promoted analysis was not granted the released slot")
+	}
+	g.release()
+}
+
+func TestParseBulkPriority(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want Priority
+	}{
+		{"", PriorityBatch},
+		{"batch", PriorityBatch},
+		{"background", PriorityBackground},
+		// 批量任务不能以交互式优先级排队
+		{"interactive", PriorityBatch},
+	} {
+		if got, err := ParseBulkPriority(c.in); err != nil || got != c.want {
+			t.Errorf("ParseBulkPriority(%q) = %q, %v, want %q", c.in, got, err, c.want)
+		}
+	}
+	if _, err := ParseBulkPriority("urgent"); err == nil {
+		t.Error("invalid priority accepted")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hhatto/gocloc"
+)
+
+// countingReportInterval 统计阶段上报已统计行数的间隔
+const countingReportInterval = 500 * time.Millisecond
+
+// Stage 分析所处的阶段
+type Stage string
+
+const (
+	StageQueued   Stage = "queued"   // 等待空闲的分析槽位
+	StageMetadata Stage = "metadata" // 检查仓库信息（大小、默认分支）
+	StageCloning  Stage = "cloning"  // 克隆仓库
+	StageCounting Stage = "counting" // 统计代码行数
+	StageBuilding Stage = "building" // 过滤并生成目录树
+)
+
+// ProgressEvent 分析进度
+type ProgressEvent struct {
+	Stage    Stage  `json:"stage"`
+	Position int    `json:"position,omitempty"` // 排队位置，从 1 开始
+	Phase    string `json:"phase,omitempty"`    // 克隆的子阶段，如 Receiving objects、Resolving deltas
+	Percent  int    `json:"percent,omitempty"`  // 克隆子阶段的百分比；组织/用户分析中为已完成的仓库比例
+	Files    int    `json:"files,omitempty"`    // 已统计的文件数，gocloc 不提供逐文件回调，每轮统计完成时更新
+	Lines    int64  `json:"lines,omitempty"`    // 已统计的行数，统计过程中定期更新
+}
+
+// Tracker 记录一次分析的进度并通知观察者
+// 合并的并发请求共用同一次分析，每个请求（任务）各自注册为观察者
+type Tracker struct {
+	mu        sync.Mutex
+	last      ProgressEvent
+	observers []func(ProgressEvent)
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// Report 上报进度，Tracker 为 nil 时忽略，调用方无需判断
+func (t *Tracker) Report(event ProgressEvent) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.last = event
+	observers := append([]func(ProgressEvent){}, t.observers...)
+	t.mu.Unlock()
+
+	for _, fn := range observers {
+		fn(event)
+	}
+}
+
+// Observe 注册观察者，已有进度时立即回调一次，便于中途加入的请求同步当前阶段
+func (t *Tracker) Observe(fn func(ProgressEvent)) {
+	if t == nil || fn == nil {
+		return
+	}
+	t.mu.Lock()
+	t.observers = append(t.observers, fn)
+	last := t.last
+	t.mu.Unlock()
+
+	if last.Stage != "" {
+		fn(last)
+	}
+}
+
+// cloneProgressRe 匹配 git clone --progress 的进度行，如 "Receiving objects:  45% (450/1000)"
+var cloneProgressRe = regexp.MustCompile(`^(?:remote: )?([A-Za-z ]+):\s+(\d+)%`)
+
+// maxCloneOutput 保留的 git 输出长度，用于错误信息
+const maxCloneOutput = 4096
+
+// readCloneProgress 读取 git clone --progress 的 stderr，解析进度并上报
+// git 用 \r 刷新同一行进度，只有以 \n 结尾的行会保留在返回的输出中
+func readCloneProgress(r io.Reader, tracker *Tracker) string {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(splitProgressLines)
+
+	var output strings.Builder
+	lastPhase, lastPercent := "", -1
+	for scanner.Scan() {
+		token := scanner.Text()
+		line := strings.TrimRight(token, "\r\n")
+
+		if m := cloneProgressRe.FindStringSubmatch(line); m != nil {
+			percent, _ := strconv.Atoi(m[2])
+			if m[1] != lastPhase || percent != lastPercent {
+				lastPhase, lastPercent = m[1], percent
+				tracker.Report(ProgressEvent{Stage: StageCloning, Phase: m[1], Percent: percent})
+			}
+		}
+		if strings.HasSuffix(token, "\n") && output.Len() < maxCloneOutput {
+			output.WriteString(line)
+			output.WriteByte('\n')
+		}
+	}
+	// 读到 EOF 前出错时继续排空，避免 git 阻塞在写 stderr 上
+	io.Copy(io.Discard, r)
+	return strings.TrimSpace(output.String())
+}
+
+// splitProgressLines 按 \r 或 \n 分行，保留分隔符以区分刷新行和完整行
+func splitProgressLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// countingProgress 统计阶段的进度：通过 gocloc 的逐行回调累计已处理的行数并定期上报
+type countingProgress struct {
+	tracker *Tracker
+	files   atomic.Int64
+	lines   atomic.Int64
+	done    chan struct{}
+	once    sync.Once
+}
+
+// trackCountingProgress 开始上报统计进度，tracker 为 nil 时只返回空操作的对象
+func trackCountingProgress(tracker *Tracker, options *gocloc.ClocOptions) *countingProgress {
+	p := &countingProgress{tracker: tracker, done: make(chan struct{})}
+	if tracker == nil {
+		return p
+	}
+
+	count := func(string) { p.lines.Add(1) }
+	options.OnCode, options.OnBlank, options.OnComment = count, count, count
+
+	go func() {
+		ticker := time.NewTicker(countingReportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.report()
+			}
+		}
+	}()
+	return p
+}
+
+// setFiles 更新已统计的文件数并立即上报
+func (p *countingProgress) setFiles(files int) {
+	p.files.Store(int64(files))
+	p.report()
+}
+
+func (p *countingProgress) report() {
+	p.tracker.Report(ProgressEvent{Stage: StageCounting, Files: int(p.files.Load()), Lines: p.lines.Load()})
+}
+
+// stop 停止定期上报，可重复调用
+func (p *countingProgress) stop() {
+	p.once.Do(func() { close(p.done) })
+}
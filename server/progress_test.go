@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+)
+
+// cloneStderr 截取自 git clone --progress 的 stderr，进度行以 \r 刷新
+const cloneStderr = "Cloning into '/tmp/goloc_repo/x'...\n" +
+	"remote: Enumerating objects: 1200, done.\n" +
+	"remote: Counting objects:   0% (1/120)\rremote: Counting objects:  50% (60/120)\rremote: Counting objects: 100% (120/120), done.\n" +
+	"remote: Compressing objects: 100% (90/90), done.\n" +
+	"Receiving objects:   0% (1/1200)\rReceiving objects:  45% (540/1200), 1.20 MiB | 2.40 MiB/s\r" +
+	"Receiving objects:  45% (545/1200), 1.21 MiB | 2.40 MiB/s\r" +
+	"Receiving objects: 100% (1200/1200), 3.10 MiB | 2.50 MiB/s, done.\n" +
+	"Resolving deltas:   0% (0/300)\rResolving deltas: 100% (300/300), done.\n"
+
+// recordProgress 返回记录进度的 Tracker 和读取记录的函数
+func recordProgress() (*Tracker, func() []string) {
+	var mu sync.Mutex
+	var events []string
+	tracker := NewTracker()
+	tracker.Observe(func(e ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf("%s %s %d", e.Stage, e.Phase, e.Percent))
+	})
+	return tracker, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, events...)
+	}
+}
+
+func TestReadCloneProgress(t *testing.T) {
+	wantEvents := []string{
+		"cloning Counting objects 0",
+		"cloning Counting objects 50",
+		"cloning Counting objects 100",
+		"cloning Compressing objects 100",
+		"cloning Receiving objects 0",
+		// 百分比不变的刷新行不重复上报
+		"cloning Receiving objects 45",
+		"cloning Receiving objects 100",
+		"cloning Resolving deltas 0",
+		"cloning Resolving deltas 100",
+	}
+	wantOutput := strings.Join([]string{
+		"Cloning into '/tmp/goloc_repo/x'...",
+		"remote: Enumerating objects: 1200, done.",
+		"remote: Counting objects: 100% (120/120), done.",
+		"remote: Compressing objects: 100% (90/90), done.",
+		"Receiving objects: 100% (1200/1200), 3.10 MiB | 2.50 MiB/s, done.",
+		"Resolving deltas: 100% (300/300), done.",
+	}, "\n")
+
+	for name, r := range map[string]io.Reader{
+		"whole":    strings.NewReader(cloneStderr),
+		"one byte": iotest.OneByteReader(strings.NewReader(cloneStderr)),
+	} {
+		tracker, events := recordProgress()
+		output := readCloneProgress(r, tracker)
+		if got := events(); !reflect.DeepEqual(got, wantEvents) {
+			t.Errorf("%s: events =\n%s\nwant\n%s", name, strings.Join(got, "\n"), strings.Join(wantEvents, "\n"))
+		}
+		if output != wantOutput {
+			t.Errorf("%s: output =\n%s\nwant\n%s", name, output, wantOutput)
+		}
+	}
+}
+
+func TestReadCloneProgressError(t *testing.T) {
+	stderr := "Cloning into '/tmp/x'...\nReceiving objects:  10% (1/10)\rfatal: repository 'https://github.com/a/b/' not found\n"
+	tracker, events := recordProgress()
+	output := readCloneProgress(strings.NewReader(stderr), tracker)
+	if got := events(); !reflect.DeepEqual(got, []string{"cloning Receiving objects 10"}) {
+		t.Errorf("events = %v", got)
+	}
+	// 错误信息保留在输出中，供按类别识别失败原因
+	if output != "Cloning into '/tmp/x'...\nfatal: repository 'https://github.com/a/b/' not found" {
+		t.Errorf("output = %q", output)
+	}
+}
+
+func TestReadCloneProgressTruncatesOutput(t *testing.T) {
+	line := strings.Repeat("x", 100) + "\n"
+	output := readCloneProgress(strings.NewReader(strings.Repeat(line, 100)), nil)
+	if len(output) > maxCloneOutput+len(line) {
+		t.Errorf("output length = %d, want at most about %d", len(output), maxCloneOutput)
+	}
+}
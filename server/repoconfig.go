@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 仓库级配置文件名
+const (
+	RepoConfigFile    = ".goloc.yml"
+	RepoConfigAltFile = ".goloc.yaml"
+	RepoIgnoreFile    = ".golocignore"
+)
+
+// maxRepoConfigSize 仓库配置文件的最大读取大小，避免恶意仓库塞入超大文件
+const maxRepoConfigSize = 64 * 1024
+
+// CustomLanguage 仓库自定义的语言
+type CustomLanguage struct {
+	Name          string           `yaml:"name" json:"name"`
+	Extensions    []string         `yaml:"extensions" json:"extensions,omitempty"`         // 扩展名，如 .tmpl
+	Patterns      []string         `yaml:"patterns" json:"patterns,omitempty"`             // gitignore 风格的路径规则
+	LineComments  []string         `yaml:"line_comments" json:"line_comments,omitempty"`   // 单行注释前缀，如 //
+	BlockComments [][]string       `yaml:"block_comments" json:"block_comments,omitempty"` // 多行注释起止，如 [["/*", "*/"]]
+	Category      LanguageCategory `yaml:"category" json:"category,omitempty"`             // 所属分类，默认 programming
+}
+
+// RepoConfig 仓库根目录下 .goloc.yml / .golocignore 声明的配置
+// 由仓库维护者提供，与服务端配置合并后生效
+type RepoConfig struct {
+	Source     []string                    `yaml:"-" json:"source"`                        // 读取到的配置文件
+	Include    []string                    `yaml:"include" json:"include,omitempty"`       // 包含规则
+	Exclude    []string                    `yaml:"exclude" json:"exclude,omitempty"`       // 排除规则
+	Categories map[string]LanguageCategory `yaml:"categories" json:"categories,omitempty"` // 语言分类覆盖
+	Tests      []string                    `yaml:"tests" json:"tests,omitempty"`           // 测试文件规则
+	Languages  []CustomLanguage            `yaml:"languages" json:"languages,omitempty"`   // 自定义语言
+}
+
+// LoadRepoConfig 读取仓库根目录下的配置文件，不存在时返回 nil
+func LoadRepoConfig(root string) *RepoConfig {
+	rc := &RepoConfig{}
+
+	for _, name := range []string{RepoConfigFile, RepoConfigAltFile} {
+		data, err := readRepoFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		if err := yaml.Unmarshal(data, rc); err != nil {
+			fmt.Printf("[RepoConfig] Failed to parse %s: %v\n", name, err)
+			continue
+		}
+		rc.Source = append(rc.Source, name)
+		break
+	}
+
+	if data, err := readRepoFile(filepath.Join(root, RepoIgnoreFile)); err == nil {
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			rc.Exclude = append(rc.Exclude, line)
+		}
+		rc.Source = append(rc.Source, RepoIgnoreFile)
+	}
+
+	if len(rc.Source) == 0 {
+		return nil
+	}
+	rc.sanitize()
+	fmt.Printf("[RepoConfig] Loaded %v: %d include, %d exclude, %d categories, %d tests, %d languages\n",
+		rc.Source, len(rc.Include), len(rc.Exclude), len(rc.Categories), len(rc.Tests), len(rc.Languages))
+	return rc
+}
+
+// readRepoFile 读取仓库中的小文件，超过大小限制时截断
+// 只读取普通文件：仓库可以把配置文件做成指向 /etc/passwd、/proc/self/environ 等的符号链接，
+// 跟随链接会把服务器上的文件当作规则读入
+func readRepoFile(filePath string) ([]byte, error) {
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		fmt.Printf("[RepoConfig] Ignoring %s: not a regular file\n", filepath.Base(filePath))
+		return nil, fmt.Errorf("%s is not a regular file", filepath.Base(filePath))
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// 打开后再次确认是同一个文件，防止检查和打开之间被替换
+	if opened, err := f.Stat(); err != nil || !os.SameFile(info, opened) {
+		return nil, fmt.Errorf("%s changed while opening", filepath.Base(filePath))
+	}
+	return io.ReadAll(io.LimitReader(f, maxRepoConfigSize))
+}
+
+// RepoConfigSummary 返回给客户端的仓库配置概要
+// 规则原文来自不可信的仓库，只返回读取到的文件和各项数量，不原样回显
+type RepoConfigSummary struct {
+	Source     []string `json:"source"`
+	Include    int      `json:"include"`
+	Exclude    int      `json:"exclude"`
+	Categories int      `json:"categories"`
+	Tests      int      `json:"tests"`
+	Languages  int      `json:"languages"`
+}
+
+// Summary 生成配置概要，rc 为 nil 时返回 nil
+func (rc *RepoConfig) Summary() *RepoConfigSummary {
+	if rc == nil {
+		return nil
+	}
+	return &RepoConfigSummary{
+		Source:     rc.Source,
+		Include:    len(rc.Include),
+		Exclude:    len(rc.Exclude),
+		Categories: len(rc.Categories),
+		Tests:      len(rc.Tests),
+		Languages:  len(rc.Languages),
+	}
+}
+
+// sanitize 丢弃非法的规则和分类，仓库配置不可信，出错时不影响整体分析
+func (rc *RepoConfig) sanitize() {
+	rc.Include = validPatterns(rc.Include)
+	rc.Exclude = validPatterns(rc.Exclude)
+	rc.Tests = validPatterns(rc.Tests)
+
+	for lang, category := range rc.Categories {
+		if !IsValidCategory(category) {
+			fmt.Printf("[RepoConfig] Ignoring invalid category %q for language %q\n", category, lang)
+			delete(rc.Categories, lang)
+		}
+	}
+
+	languages := rc.Languages[:0]
+	for _, lang := range rc.Languages {
+		if lang.Name == "" || (len(lang.Extensions) == 0 && len(lang.Patterns) == 0) {
+			fmt.Printf("[RepoConfig] Ignoring custom language without name or matcher: %+v\n", lang)
+			continue
+		}
+		if lang.Category != "" && !IsValidCategory(lang.Category) {
+			fmt.Printf("[RepoConfig] Ignoring invalid category %q for language %q\n", lang.Category, lang.Name)
+			lang.Category = ""
+		}
+		lang.Patterns = validPatterns(lang.Patterns)
+		blocks := lang.BlockComments[:0]
+		for _, b := range lang.BlockComments {
+			if len(b) == 2 && b[0] != "" && b[1] != "" {
+				blocks = append(blocks, b)
+			}
+		}
+		lang.BlockComments = blocks
+		languages = append(languages, lang)
+	}
+	rc.Languages = languages
+}
+
+// validPatterns 过滤掉无法编译的规则
+func validPatterns(patterns []string) []string {
+	var valid []string
+	for _, p := range patterns {
+		trimmed := strings.TrimSpace(p)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if _, err := CompileGlob(trimmed); err != nil {
+			fmt.Printf("[RepoConfig] Ignoring invalid pattern: %v\n", err)
+			continue
+		}
+		valid = append(valid, trimmed)
+	}
+	return valid
+}
+
+// customLanguageMatcher 自定义语言的文件匹配器
+type customLanguageMatcher struct {
+	lang       *CustomLanguage
+	extensions map[string]bool
+	patterns   *PathMatcher
+}
+
+// newCustomLanguageMatchers 为仓库的自定义语言构建匹配器
+func newCustomLanguageMatchers(rc *RepoConfig) []*customLanguageMatcher {
+	if rc == nil {
+		return nil
+	}
+	var matchers []*customLanguageMatcher
+	for i := range rc.Languages {
+		lang := &rc.Languages[i]
+		m := &customLanguageMatcher{lang: lang, extensions: make(map[string]bool)}
+		for _, ext := range lang.Extensions {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if ext != "" {
+				m.extensions[ext] = true
+			}
+		}
+		m.patterns, _ = NewPathMatcher(lang.Patterns)
+		matchers = append(matchers, m)
+	}
+	return matchers
+}
+
+// match 判断相对路径是否属于该自定义语言
+func (m *customLanguageMatcher) match(relPath string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(relPath), "."))
+	if ext != "" && m.extensions[ext] {
+		return true
+	}
+	ok, _ := m.patterns.Match(relPath)
+	return ok
+}
+
+// WithRepoConfig 将仓库配置合并到服务端配置
+// 路径规则和测试规则在服务端配置之后追加，语言分类以仓库配置为准
+func (c Config) WithRepoConfig(rc *RepoConfig) Config {
+	if rc == nil || !c.UseRepoConfig {
+		return c
+	}
+
+	merged := c
+	merged.IncludePatterns = append(append([]string{}, c.IncludePatterns...), rc.Include...)
+	merged.ExcludePatterns = append(append([]string{}, c.ExcludePatterns...), rc.Exclude...)
+
+	testPatterns := c.TestPatterns
+	if testPatterns == nil {
+		testPatterns = DefaultTestPatterns
+	}
+	merged.TestPatterns = append(append([]string{}, testPatterns...), rc.Tests...)
+
+	merged.LanguageCategories = make(map[string]LanguageCategory, len(c.LanguageCategories)+len(rc.Categories))
+	for lang, category := range c.LanguageCategories {
+		merged.LanguageCategories[lang] = category
+	}
+	for _, lang := range rc.Languages {
+		if lang.Category != "" {
+			merged.LanguageCategories[lang.Name] = lang.Category
+		}
+	}
+	for lang, category := range rc.Categories {
+		merged.LanguageCategories[lang] = category
+	}
+	return merged
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRepoConfig(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, RepoConfigFile), []byte("exclude:\n  - docs/**\ntests:\n  - \"*_check.go\"\n"), 0o644)
+	os.WriteFile(filepath.Join(root, RepoIgnoreFile), []byte("# comment\n\nvendor/\n*.pb.go\n"), 0o644)
+
+	rc := LoadRepoConfig(root)
+	if rc == nil {
+		t.Fatal("LoadRepoConfig returned nil")
+	}
+	want := RepoConfigSummary{Source: []string{RepoConfigFile, RepoIgnoreFile}, Exclude: 3, Tests: 1}
+	got := rc.Summary()
+	if len(got.Source) != 2 || got.Exclude != want.Exclude || got.Tests != want.Tests || got.Include != 0 {
+		t.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLoadRepoConfigIgnoresSymlinks(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(secret, []byte("GITHUB_TOKEN=abc\n"), 0o644)
+
+	root := t.TempDir()
+	for _, name := range []string{RepoConfigFile, RepoIgnoreFile} {
+		if err := os.Symlink(secret, filepath.Join(root, name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	if rc := LoadRepoConfig(root); rc != nil {
+		t.Errorf("LoadRepoConfig followed symlinks: %+v", rc)
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
-	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -32,10 +36,16 @@ func recoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// corsMiddleware 允许任意来源调用分析接口
+// 缓存管理接口不返回 CORS 头，网页中的脚本无法跨域调用（DELETE 的预检请求会失败）
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/cache") {
+			next.ServeHTTP(w, r)
+			return
+		}
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -60,8 +70,8 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == http.MethodPost {
-		var newCfg Config
-		if err := json.NewDecoder(r.Body).Decode(&newCfg); err != nil {
+		var update ConfigUpdate
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
 			json.NewEncoder(w).Encode(Response{
 				Code:    400,
 				Message: "Invalid JSON: " + err.Error(),
@@ -69,7 +79,15 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		appConfig.Update(newCfg)
+		if err := ValidateConfig(appConfig.Get().WithUpdate(update)); err != nil {
+			json.NewEncoder(w).Encode(Response{
+				Code:    400,
+				Message: "Invalid config: " + err.Error(),
+				Data:    nil,
+			})
+			return
+		}
+		appConfig.Update(update)
 
 		json.NewEncoder(w).Encode(Response{
 			Code:    0,
@@ -86,6 +104,68 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// decodeAnalyzeRequest 解析并校验分析请求，失败时直接写出错误响应
+func decodeAnalyzeRequest(w http.ResponseWriter, r *http.Request) (AnalyzeRequest, Config, bool) {
+	var req AnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "Invalid JSON: " + err.Error(),
+			Data:    nil,
+		})
+		return req, Config{}, false
+	}
+
+	if req.RepoURL == "" {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "repo_url is required",
+			Data:    nil,
+		})
+		return req, Config{}, false
+	}
+
+	if err := ValidateRepoURL(req.RepoURL); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return req, Config{}, false
+	}
+
+	if _, err := ParsePriority(req.Priority); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return req, Config{}, false
+	}
+
+	if _, _, err := ParseTreeOptions(req.TreeFormat, req.TreeSort); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return req, Config{}, false
+	}
+
+	// 请求中的过滤选项只对本次请求生效，不影响其他用户
+	cfg := appConfig.Get().WithOverrides(req.FilterOptions)
+	if err := ValidateConfig(cfg); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "Invalid filter options: " + err.Error(),
+			Data:    nil,
+		})
+		return req, Config{}, false
+	}
+	return req, cfg, true
+}
+
+// handleAnalyze 同步分析：提交任务并等待完成
 func handleAnalyze(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -98,12 +178,50 @@ func handleAnalyze(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	type RequestPayload struct {
-		RepoURL  string `json:"repo_url"`
-		Branch   string `json:"branch"`
-		MaxDepth int    `json:"max_depth"`
+	req, cfg, ok := decodeAnalyzeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	priority, _ := ParsePriority(req.Priority)
+	job := jobs.Submit(req, cfg, priority)
+	// 同步分析的任务 ID 不返回给客户端，结束后无需保留
+	defer jobs.Remove(job.id)
+	select {
+	case <-job.Done():
+	case <-r.Context().Done():
+		// 客户端已断开，没有其他请求等待同一分析时终止克隆
+		job.Cancel()
+		return
+	}
+
+	status := job.Status()
+	if status.Error != nil {
+		writeAnalysisError(w, status.Error)
+		return
 	}
-	var req RequestPayload
+	json.NewEncoder(w).Encode(Response{
+		Code:    0,
+		Message: "success",
+		Data:    status.Result,
+	})
+}
+
+// handleAnalyzeBatch POST 批量分析多个仓库，得到各仓库的汇总和合并后的语言统计
+// 以异步任务执行，立即返回任务 ID，结果通过 /api/jobs/{id} 查询；以 batch（或请求指定的 background）优先级排队
+func handleAnalyzeBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Only POST allowed",
+			Data:    nil,
+		})
+		return
+	}
+
+	var req BatchAnalyzeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		json.NewEncoder(w).Encode(Response{
 			Code:    400,
@@ -112,74 +230,418 @@ func handleAnalyze(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if len(req.Repos) == 0 || len(req.Repos) > maxBatchRepos {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: fmt.Sprintf("repos must contain 1 to %d repositories", maxBatchRepos),
+			Data:    nil,
+		})
+		return
+	}
+	for _, target := range req.Repos {
+		if target.RepoURL == "" {
+			json.NewEncoder(w).Encode(Response{
+				Code:    400,
+				Message: "repo_url is required for every repository",
+				Data:    nil,
+			})
+			return
+		}
+		if err := ValidateRepoURL(target.RepoURL); err != nil {
+			json.NewEncoder(w).Encode(Response{
+				Code:    400,
+				Message: fmt.Sprintf("%s: %v", target.RepoURL, err),
+				Data:    nil,
+			})
+			return
+		}
+	}
+	priority, err := ParseBulkPriority(req.Priority)
+	if err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
 
-	if req.RepoURL == "" {
+	cfg := appConfig.Get().WithOverrides(req.FilterOptions)
+	if err := ValidateConfig(cfg); err != nil {
 		json.NewEncoder(w).Encode(Response{
 			Code:    400,
-			Message: "repo_url is required",
+			Message: "Invalid filter options: " + err.Error(),
 			Data:    nil,
 		})
 		return
 	}
 
-	cacheKey := fmt.Sprintf("%s|%s", req.RepoURL, req.Branch)
-	var files []FileStat
-	var source string
+	job := jobs.SubmitBatch(req, cfg, priority)
+	json.NewEncoder(w).Encode(Response{
+		Code:    0,
+		Message: "success",
+		Data:    job.Status(),
+	})
+}
 
-	if cachedData, found := cache.Get(cacheKey); found {
-		files = cachedData
-		source = "cache"
-		fmt.Println("[Cache] Hit:", cacheKey)
-	} else {
-		source = "live"
-		fmt.Println("[Cache] Miss:", cacheKey)
+// handleAnalyzeOrg POST 分析 GitHub 组织或用户（或 GitLab 群组）的所有（满足筛选条件的）仓库
+// 仓库数量可能很多，以异步任务执行，立即返回任务 ID，结果通过 /api/jobs/{id} 查询
+func handleAnalyzeOrg(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(appConfig.Get().RequestTimeout)*time.Second)
-		defer cancel()
+	if r.Method != http.MethodPost {
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Only POST allowed",
+			Data:    nil,
+		})
+		return
+	}
 
-		var err error
-		files, err = FetchRepoStats(ctx, req.RepoURL, req.Branch)
-		if err != nil {
+	var req OrgAnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "Invalid JSON: " + err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+	if isGitLabOwner(req.Owner) {
+		if group := parseGitLabOwner(req.Owner).path; group == "" || strings.HasPrefix(group, "-") {
 			json.NewEncoder(w).Encode(Response{
-				Code:    500,
-				Message: "Analysis failed: " + err.Error(),
+				Code:    400,
+				Message: "owner must include the GitLab group path",
 				Data:    nil,
 			})
 			return
 		}
+	} else if owner := normalizeOwner(req.Owner); owner == "" || strings.Contains(owner, "/") || strings.HasPrefix(owner, "-") {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "owner must be a GitHub organization or user name, or a GitLab group URL",
+			Data:    nil,
+		})
+		return
+	}
+	filter, err := NewOwnerRepoFilter(req)
+	if err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "Invalid filter: " + err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+	priority, err := ParseBulkPriority(req.Priority)
+	if err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
 
-		cache.Set(cacheKey, files, appConfig.Get().CacheTTL)
+	cfg := appConfig.Get().WithOverrides(req.FilterOptions)
+	if err := ValidateConfig(cfg); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "Invalid filter options: " + err.Error(),
+			Data:    nil,
+		})
+		return
 	}
 
-	// 根据当前配置过滤文件（缓存的是完整数据）
-	cfg := appConfig.Get()
-	filteredFiles := make([]FileStat, 0, len(files))
-	for _, f := range files {
-		if ShouldIncludeLanguage(f.Language, cfg.IncludeDataFiles, cfg.IncludeDocumentation) {
-			filteredFiles = append(filteredFiles, f)
+	job := jobs.SubmitOrg(req, filter, cfg, priority)
+	json.NewEncoder(w).Encode(Response{
+		Code:    0,
+		Message: "success",
+		Data:    job.Status(),
+	})
+}
+
+// handleJobs POST 提交异步分析任务，立即返回任务 ID
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Only POST allowed",
+			Data:    nil,
+		})
+		return
+	}
+
+	req, cfg, ok := decodeAnalyzeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	priority, _ := ParsePriority(req.Priority)
+	job := jobs.Submit(req, cfg, priority)
+	json.NewEncoder(w).Encode(Response{
+		Code:    0,
+		Message: "success",
+		Data:    job.Status(),
+	})
+}
+
+// handleJob GET /api/jobs/{id} 查询任务状态，完成后包含分析结果
+// GET /api/jobs/{id}/events 以 Server-Sent Events 推送任务进度
+// DELETE /api/jobs/{id} 取消任务
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+	id, events := strings.CutSuffix(id, "/events")
+	if r.Method != http.MethodGet && (r.Method != http.MethodDelete || events) {
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Only GET and DELETE allowed",
+			Data:    nil,
+		})
+		return
+	}
+
+	job, found := jobs.Get(id)
+	if !found || strings.Contains(id, "/") {
+		json.NewEncoder(w).Encode(Response{
+			Code:    404,
+			Message: "job not found",
+			Data:    nil,
+		})
+		return
+	}
+	if events {
+		handleJobEvents(w, r, job)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		if !job.Cancel() {
+			json.NewEncoder(w).Encode(Response{
+				Code:    409,
+				Message: "job already finished",
+				Data:    job.Status(),
+			})
+			return
+		}
+		<-job.Done()
+		fmt.Printf("[Job] Canceled %s\n", id)
+	}
+	json.NewEncoder(w).Encode(Response{
+		Code:    0,
+		Message: "success",
+		Data:    job.Status(),
+	})
+}
+
+// jobHeartbeatInterval SSE 连接的心跳间隔，避免代理因长时间无数据断开连接
+const jobHeartbeatInterval = 15 * time.Second
+
+// handleJobEvents 推送任务进度：连接后先发送一次 status 事件（当前状态），之后每次进度发送 progress 事件，
+// 任务结束时发送 done 或 failed 事件（完整的任务状态，包含结果或错误）并关闭连接
+func handleJobEvents(w http.ResponseWriter, r *http.Request, job *Job) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		json.NewEncoder(w).Encode(Response{
+			Code:    500,
+			Message: "streaming not supported",
+			Data:    nil,
+		})
+		return
+	}
+
+	// 先订阅再读取状态，两者之间的进度不会丢失
+	progress, unsubscribe := job.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	writeSSE(w, "status", job.Status())
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(jobHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-progress:
+			if !ok {
+				status := job.Status()
+				writeSSE(w, string(status.State), status)
+				flusher.Flush()
+				return
+			}
+			writeSSE(w, "progress", event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeSSE 写出一条 Server-Sent Event，data 为 JSON
+func writeSSE(w http.ResponseWriter, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
+
+// writeAnalysisError 输出分析失败的响应，带类别的错误使用对应的错误码并在 data 中返回详情
+func writeAnalysisError(w http.ResponseWriter, err error) {
+	resp := Response{
+		Code:    500,
+		Message: "Analysis failed: " + err.Error(),
+		Data:    nil,
+	}
+	var analysisErr *AnalysisError
+	if errors.As(err, &analysisErr) {
+		resp.Code = analysisErr.Code()
+		resp.Data = analysisErr
+		if analysisErr.Cached {
+			resp.Message += fmt.Sprintf(" (cached, retry in %ds)", analysisErr.RetryAfter)
 		}
 	}
-	fmt.Printf("[Filter] Applied language filter: %d -> %d files\n", len(files), len(filteredFiles))
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CategoryInfo 分类信息
+type CategoryInfo struct {
+	Category  LanguageCategory `json:"category"`
+	Include   bool             `json:"include"`
+	Languages []string         `json:"languages"`
+}
+
+// queryConfig 在全局配置上应用查询参数中的请求级过滤选项（见 ParseFilterQuery），失败时直接写出错误响应
+// 与分析时使用相同的选项才能命中同一份缓存，得到与已展示结果一致的过滤
+func queryConfig(w http.ResponseWriter, query url.Values) (Config, bool) {
+	opts, err := ParseFilterQuery(query)
+	if err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "Invalid filter options: " + err.Error(),
+			Data:    nil,
+		})
+		return Config{}, false
+	}
+	cfg := appConfig.Get().WithOverrides(opts)
+	if err := ValidateConfig(cfg); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "Invalid filter options: " + err.Error(),
+			Data:    nil,
+		})
+		return Config{}, false
+	}
+	return cfg, true
+}
+
+// handleTree GET /api/tree?repo=&ref=&path=&depth=&format=&sort= 返回分析结果中 path 下的子目录树，供界面按需展开
+// ref 为分支名（为空时为默认分支）或完整的提交 SHA，depth 默认为 1
+// format=ordered 时返回子节点有序的目录树，百分比相对于 path 的父目录和整个仓库
+func handleTree(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	depth := req.MaxDepth
-	if depth <= 0 {
-		depth = cfg.DefaultDepth
+	if r.Method != http.MethodGet {
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Only GET allowed",
+			Data:    nil,
+		})
+		return
 	}
-	projectName := extractProjectName(req.RepoURL)
-	treeRoot := BuildTree(filteredFiles, depth, projectName)
 
-	// 计算完整的语言统计（基于所有过滤后的文件，不受深度限制）
-	languages := CalculateLanguageStats(filteredFiles)
+	query := r.URL.Query()
+	repoURL := query.Get("repo")
+	if repoURL == "" {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "repo is required",
+			Data:    nil,
+		})
+		return
+	}
+	if err := ValidateRepoURL(repoURL); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+	depth := 1
+	if val := query.Get("depth"); val != "" {
+		i, err := strconv.Atoi(val)
+		if err != nil || i <= 0 {
+			json.NewEncoder(w).Encode(Response{
+				Code:    400,
+				Message: "depth must be a positive integer",
+				Data:    nil,
+			})
+			return
+		}
+		depth = i
+	}
+	format, sortBy, err := ParseTreeOptions(query.Get("format"), query.Get("sort"))
+	if err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
 
-	result := AnalyzeResult{
-		Source:    source,
-		Repo:      req.RepoURL,
-		Branch:    req.Branch,
-		Timestamp: time.Now().Unix(),
-		Data:      treeRoot,
-		Languages: languages,
+	cfg, ok := queryConfig(w, query)
+	if !ok {
+		return
+	}
+	ref := query.Get("ref")
+	loaded, err := LoadRefStats(r.Context(), repoURL, ref, cfg)
+	if err != nil {
+		writeAnalysisError(w, err)
+		return
+	}
+	filtered, _, err := filterRepoStats(cfg, loaded.Stats)
+	if err != nil {
+		writeAnalysisError(w, err)
+		return
 	}
 
+	path := strings.Trim(query.Get("path"), "/")
+	node := BuildSubtree(filtered.Files, path, depth, extractProjectName(repoURL))
+	if node == nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    404,
+			Message: fmt.Sprintf("path %q not found", path),
+			Data:    nil,
+		})
+		return
+	}
+	result := SubtreeResult{
+		Repo:   repoURL,
+		Ref:    ref,
+		Commit: loaded.Stats.Commit,
+		Path:   path,
+		Depth:  depth,
+		Source: loaded.Source,
+	}
+	if format == TreeFormatOrdered {
+		parent := ""
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			parent = path[:i]
+		}
+		result.Tree = OrderTree(node, sortBy, linesUnder(filtered.Files, parent), linesUnder(filtered.Files, ""))
+	} else {
+		result.Data = node
+	}
 	json.NewEncoder(w).Encode(Response{
 		Code:    0,
 		Message: "success",
@@ -187,6 +649,295 @@ func handleAnalyze(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleFiles GET /api/files?repo=&ref=&... 分析结果的文件列表，支持过滤、排序和分页，参数见 ParseFileQuery
+func handleFiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Only GET allowed",
+			Data:    nil,
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	repoURL := query.Get("repo")
+	if repoURL == "" {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "repo is required",
+			Data:    nil,
+		})
+		return
+	}
+	if err := ValidateRepoURL(repoURL); err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+	q, err := ParseFileQuery(query)
+	if err != nil {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: err.Error(),
+			Data:    nil,
+		})
+		return
+	}
+
+	cfg, ok := queryConfig(w, query)
+	if !ok {
+		return
+	}
+
+	// 翻页时读取第一页所用的提交
+	ref := query.Get("ref")
+	revision := ref
+	if q.Cursor.Commit != "" {
+		revision = q.Cursor.Commit
+	}
+	loaded, err := LoadRefStats(r.Context(), repoURL, revision, cfg)
+	if err != nil {
+		writeAnalysisError(w, err)
+		return
+	}
+
+	files, total, summary, err := ListFiles(loaded.Stats, cfg, q)
+	if err != nil {
+		writeAnalysisError(w, err)
+		return
+	}
+	result := FileListResult{
+		Repo:    repoURL,
+		Ref:     ref,
+		Commit:  loaded.Stats.Commit,
+		Source:  loaded.Source,
+		Total:   total,
+		Summary: summary,
+		Files:   files,
+	}
+	if next := q.Cursor.Offset + len(files); next < total {
+		result.NextCursor = encodeFileCursor(fileCursor{Commit: loaded.Stats.Commit, Offset: next})
+	}
+	json.NewEncoder(w).Encode(Response{
+		Code:    0,
+		Message: "success",
+		Data:    result,
+	})
+}
+
+// handleCategories 返回当前生效的语言分类表
+func handleCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Only GET allowed",
+			Data:    nil,
+		})
+		return
+	}
+
+	cfg := appConfig.Get()
+	classifier := NewFileClassifier(cfg)
+
+	grouped := make(map[LanguageCategory][]string)
+	for lang, category := range classifier.Table() {
+		grouped[category] = append(grouped[category], lang)
+	}
+
+	categories := make([]CategoryInfo, 0, len(AllCategories))
+	for _, category := range AllCategories {
+		languages := grouped[category]
+		sort.Strings(languages)
+		if languages == nil {
+			languages = []string{}
+		}
+		categories = append(categories, CategoryInfo{
+			Category:  category,
+			Include:   classifier.IncludeCategory(category),
+			Languages: languages,
+		})
+	}
+
+	json.NewEncoder(w).Encode(Response{
+		Code:    0,
+		Message: "success",
+		Data: map[string]interface{}{
+			"categories":    categories,
+			"test_patterns": cfg.TestPatterns,
+		},
+	})
+}
+
+// requireAdmin 校验 Authorization: Bearer <ADMIN_TOKEN>，失败时直接写出错误响应
+// 未配置 ADMIN_TOKEN 时拒绝所有请求
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	adminToken := appConfig.Get().AdminToken
+	if adminToken == "" {
+		json.NewEncoder(w).Encode(Response{
+			Code:    403,
+			Message: "cache administration is disabled, set ADMIN_TOKEN to enable it",
+			Data:    nil,
+		})
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		json.NewEncoder(w).Encode(Response{
+			Code:    401,
+			Message: "invalid admin token",
+			Data:    nil,
+		})
+		return false
+	}
+	return true
+}
+
+// handleCache GET 返回缓存统计，DELETE 清空全部缓存（需要 ADMIN_TOKEN）
+func handleCache(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(Response{
+			Code:    0,
+			Message: "success",
+			Data:    cache.Stats(),
+		})
+	case http.MethodDelete:
+		if !requireAdmin(w, r) {
+			return
+		}
+		cache.Clear()
+		failures.Clear()
+		fmt.Println("[Cache] Flushed all entries")
+		json.NewEncoder(w).Encode(Response{
+			Code:    0,
+			Message: "success",
+			Data:    cache.Stats(),
+		})
+	default:
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Method not allowed",
+			Data:    nil,
+		})
+	}
+}
+
+// handleCacheEntries GET 列出缓存条目，DELETE 按仓库或键前缀清理，都需要 ADMIN_TOKEN（条目中包含私有仓库的地址）
+// 查询参数：repo 仓库地址（与缓存键使用相同的规范化），prefix 缓存键前缀
+func handleCacheEntries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	repo := r.URL.Query().Get("repo")
+	prefix := r.URL.Query().Get("prefix")
+	match := func(info CacheEntryInfo) bool {
+		if repo != "" && info.Repo != NormalizeRepoURL(repo) {
+			return false
+		}
+		return strings.HasPrefix(info.Key, prefix)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !requireAdmin(w, r) {
+			return
+		}
+		entries := []CacheEntryInfo{}
+		for _, info := range cache.List() {
+			if match(info) {
+				entries = append(entries, info)
+			}
+		}
+		json.NewEncoder(w).Encode(Response{
+			Code:    0,
+			Message: "success",
+			Data: map[string]interface{}{
+				"total":   len(entries),
+				"entries": entries,
+			},
+		})
+	case http.MethodDelete:
+		if !requireAdmin(w, r) {
+			return
+		}
+		// 清空全部需要显式调用 DELETE /api/cache，避免漏传参数误删
+		if repo == "" && prefix == "" {
+			json.NewEncoder(w).Encode(Response{
+				Code:    400,
+				Message: "repo or prefix is required",
+				Data:    nil,
+			})
+			return
+		}
+		purged := PurgeCache(cache, match)
+		if repo != "" {
+			failures.PurgeRepo(repo)
+		}
+		fmt.Printf("[Cache] Purged %d entries (repo: %q, prefix: %q)\n", purged, repo, prefix)
+		json.NewEncoder(w).Encode(Response{
+			Code:    0,
+			Message: "success",
+			Data:    map[string]int{"purged": purged},
+		})
+	default:
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Method not allowed",
+			Data:    nil,
+		})
+	}
+}
+
+// handleCacheEntry 返回单个缓存条目的元数据（需要 ADMIN_TOKEN），查询参数 key 为完整的缓存键
+func handleCacheEntry(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		json.NewEncoder(w).Encode(Response{
+			Code:    405,
+			Message: "Only GET allowed",
+			Data:    nil,
+		})
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		json.NewEncoder(w).Encode(Response{
+			Code:    400,
+			Message: "key is required",
+			Data:    nil,
+		})
+		return
+	}
+	info, found := cache.Info(key)
+	if !found {
+		json.NewEncoder(w).Encode(Response{
+			Code:    404,
+			Message: "cache entry not found",
+			Data:    nil,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(Response{
+		Code:    0,
+		Message: "success",
+		Data:    info,
+	})
+}
+
 func extractProjectName(repoURL string) string {
 	// 移除.git后缀
 	cleaned := repoURL
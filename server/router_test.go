@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheDeleteRequiresAdminToken(t *testing.T) {
+	appConfig = NewAppConfig()
+	cache = NewCache(time.Hour, CacheLimits{})
+	failures = NewFailureCache(time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/cache", handleCache)
+	mux.HandleFunc("/api/cache/entries", handleCacheEntries)
+	mux.HandleFunc("/api/cache/entry", handleCacheEntry)
+	mux.HandleFunc("/api/config", handleConfig)
+	handler := corsMiddleware(mux)
+
+	do := func(method, path, auth string) Response {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var resp Response
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+		return resp
+	}
+
+	// 未配置 ADMIN_TOKEN 时 DELETE 和条目查询一律拒绝
+	appConfig.inner.AdminToken = ""
+	if resp := do(http.MethodDelete, "/api/cache", "Bearer anything"); resp.Code != 403 {
+		t.Errorf("DELETE without configured token: code = %d, want 403", resp.Code)
+	}
+	if resp := do(http.MethodGet, "/api/cache/entries", ""); resp.Code != 403 {
+		t.Errorf("GET entries without configured token: code = %d, want 403", resp.Code)
+	}
+
+	appConfig.inner.AdminToken = "s3cret"
+	cases := []struct {
+		method string
+		path   string
+		auth   string
+		want   int
+	}{
+		{http.MethodDelete, "/api/cache", "", 401},
+		{http.MethodDelete, "/api/cache", "Bearer wrong", 401},
+		{http.MethodDelete, "/api/cache", "s3cret", 401},
+		{http.MethodDelete, "/api/cache", "Bearer s3cret", 0},
+		{http.MethodDelete, "/api/cache/entries?prefix=x", "Bearer wrong", 401},
+		{http.MethodDelete, "/api/cache/entries?prefix=x", "Bearer s3cret", 0},
+		// 条目中包含仓库地址，查询同样需要令牌；统计信息不需要
+		{http.MethodGet, "/api/cache/entries", "", 401},
+		{http.MethodGet, "/api/cache/entries?repo=https://github.com/a/b", "Bearer wrong", 401},
+		{http.MethodGet, "/api/cache/entries", "Bearer s3cret", 0},
+		{http.MethodGet, "/api/cache/entry?key=x", "", 401},
+		{http.MethodGet, "/api/cache/entry?key=x", "Bearer s3cret", 404},
+		{http.MethodGet, "/api/cache", "", 0},
+	}
+	for _, c := range cases {
+		if resp := do(c.method, c.path, c.auth); resp.Code != c.want {
+			t.Errorf("%s %s (auth %q): code = %d, want %d", c.method, c.path, c.auth, resp.Code, c.want)
+		}
+	}
+
+	// 缓存管理接口不返回 CORS 头，其他接口保持原样
+	for path, wantCORS := range map[string]bool{"/api/cache": false, "/api/cache/entries": false, "/api/config": true} {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", "https://evil.example")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		got := rec.Header().Get("Access-Control-Allow-Origin") != ""
+		if got != wantCORS {
+			t.Errorf("OPTIONS %s: CORS header present = %v, want %v", path, got, wantCORS)
+		}
+	}
+}
+
+// sseEvent 一条 Server-Sent Event
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readSSE 读取事件直到连接关闭，每读到一条事件调用一次 onEvent
+func readSSE(t *testing.T, body io.Reader, onEvent func(sseEvent)) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && current.name != "":
+			events = append(events, current)
+			onEvent(current)
+			current = sseEvent{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+// useJobServer 替换全局的任务管理器并启动只含任务和同步分析接口的服务
+func useJobServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	saved := jobs
+	jobs = NewJobManager(time.Hour)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/jobs/", handleJob)
+	mux.HandleFunc("/api/analyze", handleAnalyze)
+	mux.HandleFunc("/api/analyze/batch", handleAnalyzeBatch)
+	server := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		server.Close()
+		jobs = saved
+	})
+	return server
+}
+
+func TestJobEventsStream(t *testing.T) {
+	server := useJobServer(t)
+
+	gate := make(chan struct{})
+	job := &Job{
+		kind: JobAnalyze,
+		repo: "https://github.com/a/b",
+		task: func(ctx context.Context, job *Job) (interface{}, error) {
+			<-gate
+			job.onProgress(ProgressEvent{Stage: StageCloning, Phase: "Receiving objects", Percent: 45})
+			job.onProgress(ProgressEvent{Stage: StageCounting, Files: 10})
+			return map[string]int{"files": 10}, nil
+		},
+	}
+	jobs.submit(job, PriorityInteractive)
+
+	resp, err := http.Get(server.URL + "/api/jobs/" + job.id + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// 收到首个 status 事件（已订阅）后再让任务继续
+	events := readSSE(t, resp.Body, func(e sseEvent) {
+		if e.name == "status" {
+			close(gate)
+		}
+	})
+
+	var names []string
+	for _, e := range events {
+		names = append(names, e.name)
+	}
+	if want := []string{"status", "progress", "progress", "done"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("events = %v, want %v", names, want)
+	}
+	var progress ProgressEvent
+	if err := json.Unmarshal([]byte(events[1].data), &progress); err != nil || progress.Phase != "Receiving objects" || progress.Percent != 45 {
+		t.Errorf("progress event = %s", events[1].data)
+	}
+	var status JobStatus
+	if err := json.Unmarshal([]byte(events[3].data), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.State != JobDone || status.ID != job.id || status.Result == nil {
+		t.Errorf("final status = %s", events[3].data)
+	}
+
+	// 任务已结束时只发送当前状态和结束事件
+	resp, err = http.Get(server.URL + "/api/jobs/" + job.id + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events = readSSE(t, resp.Body, func(sseEvent) {})
+	if len(events) != 2 || events[0].name != "status" || events[1].name != "done" {
+		t.Errorf("events for a finished job = %+v", events)
+	}
+}
+
+// flightWaiters 返回 key 对应的进行中分析的等待者数量，没有进行中的分析时为 -1
+func flightWaiters(key string) int {
+	analyses.mu.Lock()
+	defer analyses.mu.Unlock()
+	if f, ok := analyses.flights[key]; ok {
+		return f.waiters
+	}
+	return -1
+}
+
+// deleteJob 调用 DELETE /api/jobs/{id}
+func deleteJob(t *testing.T, server *httptest.Server, id string) (Response, JobStatus) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodDelete, server.URL+"/api/jobs/"+id, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var status JobStatus
+	body := Response{Data: &status}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return body, status
+}
+
+func TestCancelJobWithSharedAnalysis(t *testing.T) {
+	cfg := NewAppConfig().Get()
+	api := useFakeGithub(t, cfg)
+	server := useJobServer(t)
+
+	req := AnalyzeRequest{RepoURL: "http://127.0.0.1:1/cancel/shared", Branch: "main"}
+	key := BuildCacheKey(req.RepoURL, branchRevision("main"), cfg.AnalysisOptions())
+	first := jobs.Submit(req, cfg, PriorityInteractive)
+	second := jobs.Submit(req, cfg, PriorityInteractive)
+	waitFor(t, func() bool { return flightWaiters(key) == 2 && api.requests.Load() == 1 })
+
+	resp, status := deleteJob(t, server, first.id)
+	if resp.Code != 0 || status.State != JobCanceled || status.Error == nil || status.Error.Class != ErrorCanceled {
+		t.Fatalf("DELETE = %+v, status %+v, want canceled", resp, status)
+	}
+	if resp, _ := deleteJob(t, server, first.id); resp.Code != 409 {
+		t.Errorf("second DELETE code = %d, want 409", resp.Code)
+	}
+
+	// 另一个任务仍在等待，共享的分析继续进行
+	if n := flightWaiters(key); n != 1 {
+		t.Fatalf("flight waiters = %d, want 1", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := api.canceled.Load(); n != 0 {
+		t.Errorf("shared analysis was stopped (%d canceled requests)", n)
+	}
+
+	api.open()
+	<-second.Done()
+	if s := second.Status(); s.State != JobFailed || s.Error.Class != ErrorNotFound {
+		t.Errorf("remaining job = %s (%+v), want the shared analysis result (not_found)", s.State, s.Error)
+	}
+}
+
+func TestCancelJobStopsAnalysis(t *testing.T) {
+	cfg := NewAppConfig().Get()
+	api := useFakeGithub(t, cfg)
+	server := useJobServer(t)
+
+	req := AnalyzeRequest{RepoURL: "http://127.0.0.1:1/cancel/last", Branch: "main"}
+	key := BuildCacheKey(req.RepoURL, branchRevision("main"), cfg.AnalysisOptions())
+	job := jobs.Submit(req, cfg, PriorityInteractive)
+	waitFor(t, func() bool { return api.requests.Load() == 1 })
+
+	// 最后一个等待者离开：分析被取消，进行中的请求随之中断
+	if resp, status := deleteJob(t, server, job.id); resp.Code != 0 || status.State != JobCanceled {
+		t.Fatalf("DELETE = %+v, status %+v, want canceled", resp, status)
+	}
+	waitFor(t, func() bool { return api.canceled.Load() == 1 })
+	if n := flightWaiters(key); n != -1 {
+		t.Errorf("canceled analysis still registered with %d waiters", n)
+	}
+	// 取消不计为失败，之后的请求重新分析
+	if _, found := checkFailure(req.RepoURL, branchRevision("main"), cfg); found {
+		t.Error("cancellation was cached as a failure")
+	}
+}
+
+func TestAnalyzeClientDisconnectStopsAnalysis(t *testing.T) {
+	cfg := NewAppConfig().Get()
+	api := useFakeGithub(t, cfg)
+	server := useJobServer(t)
+
+	repoURL := "http://127.0.0.1:1/cancel/disconnect"
+	key := BuildCacheKey(repoURL, branchRevision("main"), cfg.AnalysisOptions())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/api/analyze",
+			strings.NewReader(`{"repo_url": "`+repoURL+`", "branch": "main"}`))
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	waitFor(t, func() bool { return api.requests.Load() == 1 })
+
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("request completed, want it aborted")
+	}
+	waitFor(t, func() bool { return api.canceled.Load() == 1 && flightWaiters(key) == -1 })
+	// 同步分析的任务结束后即删除
+	waitFor(t, func() bool {
+		jobs.mu.RLock()
+		defer jobs.mu.RUnlock()
+		return len(jobs.jobs) == 0
+	})
+}
+
+func TestAnalyzeBatchReturnsJob(t *testing.T) {
+	cfg := NewAppConfig().Get()
+	api := useFakeGithub(t, cfg)
+	api.open()
+	server := useJobServer(t)
+
+	// 批量分析以任务执行，客户端要求的 interactive 按 batch 排队
+	body := `{"repos": [{"repo_url": "http://127.0.0.1:1/batch/one", "branch": "main"}], "priority": "interactive"}`
+	resp, err := http.Post(server.URL+"/api/analyze/batch", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Code int       `json:"code"`
+		Data JobStatus `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Code != 0 || result.Data.ID == "" || result.Data.Kind != JobBatch || result.Data.Priority != PriorityBatch {
+		t.Fatalf("response = %+v, want a batch job at batch priority", result)
+	}
+
+	job, ok := jobs.Get(result.Data.ID)
+	if !ok {
+		t.Fatal("submitted job not found")
+	}
+	select {
+	case <-job.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch job did not finish")
+	}
+	if batch, ok := job.Status().Result.(*BatchResult); !ok || batch.Failed != 1 {
+		t.Errorf("result = %+v, want the unreachable repo failed", job.Status().Result)
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hhatto/gocloc"
@@ -21,6 +26,9 @@ type RepoMeta struct {
 	DefaultBranch string `json:"default_branch"`
 }
 
+// lsRemoteTimeout 解析远端分支的超时时间，该操作不下载仓库内容，应当很快
+const lsRemoteTimeout = 15 * time.Second
+
 // buildExcludeDirRegex 构建排除目录的正则表达式
 func buildExcludeDirRegex(excludeDirs []string) *regexp.Regexp {
 	if len(excludeDirs) == 0 {
@@ -46,19 +54,55 @@ func buildExcludeDirRegex(excludeDirs []string) *regexp.Regexp {
 	return re
 }
 
-func FetchRepoStats(ctx context.Context, repoURL string, branch string) ([]FileStat, error) {
+// normalizeExcludeDirs 去掉首尾空白、空项和重复项并排序
+// 缓存键和排除规则都基于这个结果，保证同一缓存键对应同样的统计范围
+func normalizeExcludeDirs(excludeDirs []string) []string {
+	set := make(map[string]bool, len(excludeDirs))
+	dirs := make([]string, 0, len(excludeDirs))
+	for _, dir := range excludeDirs {
+		dir = strings.TrimSpace(dir)
+		if dir != "" && !set[dir] {
+			set[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// AnalysisOptions 影响分析结果（即缓存内容）的选项
+// 分类和路径规则在读取缓存后再应用，不属于这里
+type AnalysisOptions struct {
+	ExcludeDirs   []string
+	UseRepoConfig bool
+}
+
+// AnalysisOptions 提取配置中影响分析结果的部分
+func (c Config) AnalysisOptions() AnalysisOptions {
+	return AnalysisOptions{
+		ExcludeDirs:   normalizeExcludeDirs(c.ExcludeDirs),
+		UseRepoConfig: c.UseRepoConfig,
+	}
+}
+
+// FetchRepoStats 克隆并统计仓库，tracker 可为 nil
+func FetchRepoStats(ctx context.Context, repoURL string, branch string, opts AnalysisOptions, tracker *Tracker) (*RepoStats, error) {
 	cfg := appConfig.Get()
+	tracker.Report(ProgressEvent{Stage: StageMetadata})
 
 	// Get repository metadata (size check + default branch)
 	meta, err := getRepoMeta(ctx, repoURL, cfg.GithubToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get repo metadata: %v", err)
+		return nil, newAnalysisError(errorClass(err), "failed to get repo metadata: %v", err)
 	}
 
 	// Check repo size
 	repoSizeMB := meta.Size / 1024
 	if repoSizeMB > cfg.MaxRepoSizeMB {
-		return nil, fmt.Errorf("repo too large: %d MB exceeds limit %d MB", repoSizeMB, cfg.MaxRepoSizeMB)
+		tooLarge := newAnalysisError(ErrorTooLarge, "repo too large: %d MB exceeds limit %d MB", repoSizeMB, cfg.MaxRepoSizeMB)
+		tooLarge.SizeMB = repoSizeMB
+		tooLarge.LimitMB = cfg.MaxRepoSizeMB
+		return nil, tooLarge
 	}
 	fmt.Printf("[Pre-Check] Passed. Size: %d MB, Default branch: %s\n", repoSizeMB, meta.DefaultBranch)
 
@@ -76,23 +120,39 @@ func FetchRepoStats(ctx context.Context, repoURL string, branch string) ([]FileS
 	defer os.RemoveAll(tmpDir)
 
 	// Clone repository
-	if err := cloneRepo(ctx, repoURL, targetBranch, tmpDir); err != nil {
+	tracker.Report(ProgressEvent{Stage: StageCloning})
+	if err := cloneRepo(ctx, repoURL, targetBranch, tmpDir, tracker); err != nil {
 		return nil, err
 	}
-	fmt.Printf("[Process] Successfully cloned branch: %s\n", targetBranch)
+	commit, err := headCommit(ctx, tmpDir)
+	if err != nil {
+		fmt.Printf("[Warning] Failed to read cloned commit: %v\n", err)
+	}
+	fmt.Printf("[Process] Successfully cloned branch: %s (commit %s)\n", targetBranch, commit)
+
+	tracker.Report(ProgressEvent{Stage: StageCounting})
+
+	// 读取仓库级配置（.goloc.yml / .golocignore）
+	var repoConfig *RepoConfig
+	if opts.UseRepoConfig {
+		repoConfig = LoadRepoConfig(tmpDir)
+	}
 
 	languages := gocloc.NewDefinedLanguages()
 	options := gocloc.NewClocOptions()
 
 	// 设置排除目录
-	if len(cfg.ExcludeDirs) > 0 {
-		excludeRegex := buildExcludeDirRegex(cfg.ExcludeDirs)
+	if len(opts.ExcludeDirs) > 0 {
+		excludeRegex := buildExcludeDirRegex(opts.ExcludeDirs)
 		if excludeRegex != nil {
 			options.ReNotMatchDir = excludeRegex
-			fmt.Printf("[Filter] Excluding directories: %v\n", cfg.ExcludeDirs)
+			fmt.Printf("[Filter] Excluding directories: %v\n", opts.ExcludeDirs)
 		}
 	}
 
+	progress := trackCountingProgress(tracker, options)
+	defer progress.stop()
+
 	processor := gocloc.NewProcessor(languages, options)
 	result, err := processor.Analyze([]string{tmpDir})
 	if err != nil {
@@ -121,18 +181,232 @@ func FetchRepoStats(ctx context.Context, repoURL string, branch string) ([]FileS
 			})
 		}
 	}
-	fmt.Printf("[Process] Analysis done. Total files: %d\n", len(stats))
-	return stats, nil
+
+	progress.setFiles(len(stats))
+
+	// gocloc 无法识别的锁文件（yarn.lock、go.sum 等）与仓库自定义语言单独计数
+	extraStats := collectExtraFiles(tmpDir, result.Files, options, newCustomLanguageMatchers(repoConfig))
+	stats = mergeFileStats(stats, extraStats)
+	progress.stop()
+	progress.setFiles(len(stats))
+
+	// 统计被排除目录中的内容，便于判断排除规则是否影响了结果
+	excludedDirs := countExcludedDirs(tmpDir, opts.ExcludeDirs)
+
+	fmt.Printf("[Process] Analysis done. Total files: %d (extra files: %d)\n", len(stats), len(extraStats))
+	return &RepoStats{
+		Branch:       targetBranch,
+		Commit:       commit,
+		AnalyzedAt:   time.Now().Unix(),
+		Files:        stats,
+		ExcludedDirs: excludedDirs,
+		RepoConfig:   repoConfig,
+	}, nil
+}
+
+// mergeFileStats 合并额外统计的文件，路径相同时以额外统计为准
+func mergeFileStats(stats []FileStat, extra []FileStat) []FileStat {
+	if len(extra) == 0 {
+		return stats
+	}
+	index := make(map[string]int, len(stats))
+	for i, f := range stats {
+		index[f.Path] = i
+	}
+	for _, f := range extra {
+		if i, ok := index[f.Path]; ok {
+			stats[i] = f
+			continue
+		}
+		stats = append(stats, f)
+	}
+	return stats
+}
+
+// countExcludedDirs 统计每条排除规则命中的目录及其中的文件数和行数
+// 被排除的多是 node_modules、vendor 这类很大的依赖目录，不做语言识别，只按行粗略计数（见 countFileLines），
+// 避免报告本身的开销接近一次完整分析
+func countExcludedDirs(root string, excludeDirs []string) []ExcludedDirStat {
+	if len(excludeDirs) == 0 {
+		return []ExcludedDirStat{}
+	}
+
+	patterns := make(map[string]bool, len(excludeDirs))
+	for _, dir := range excludeDirs {
+		patterns[dir] = true
+	}
+
+	// 只记录最外层命中的目录，嵌套的排除目录归入外层规则
+	matched := make(map[string][]string)
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() || path == root {
+			return nil
+		}
+		name := info.Name()
+		if name == ".git" {
+			return filepath.SkipDir
+		}
+		if patterns[name] {
+			matched[name] = append(matched[name], path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+
+	var result []ExcludedDirStat
+	for _, pattern := range excludeDirs {
+		dirs, ok := matched[pattern]
+		if !ok {
+			continue
+		}
+		stat := ExcludedDirStat{Pattern: pattern, Dirs: len(dirs)}
+		for _, dir := range dirs {
+			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || !info.Mode().IsRegular() {
+					return nil
+				}
+				if lines, nonBlank, ok := countFileLines(path); ok {
+					stat.Files++
+					stat.Lines += lines
+					stat.Code += nonBlank
+				}
+				return nil
+			})
+		}
+		result = append(result, stat)
+		delete(matched, pattern)
+	}
+	if result == nil {
+		result = []ExcludedDirStat{}
+	}
+	return result
+}
+
+// countFileLines 统计文件的行数和非空行数，不区分代码和注释；二进制文件（开头包含 NUL）返回 ok=false
+func countFileLines(path string) (lines int, nonBlank int, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	buf := make([]byte, 32*1024)
+	first := true
+	blank := true
+	for {
+		n, err := f.Read(buf)
+		chunk := buf[:n]
+		if first && n > 0 {
+			if bytes.IndexByte(chunk[:min(n, 8000)], 0) >= 0 {
+				return 0, 0, false
+			}
+			first = false
+		}
+		for _, c := range chunk {
+			switch c {
+			case '\n':
+				lines++
+				if !blank {
+					nonBlank++
+				}
+				blank = true
+			case ' ', '\t', '\r':
+			default:
+				blank = false
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	// 最后一行没有换行符
+	if !blank {
+		lines++
+		nonBlank++
+	}
+	return lines, nonBlank, true
+}
+
+// collectExtraFiles 遍历仓库，统计 gocloc 未覆盖的文件：
+// 未识别的依赖锁文件，以及仓库 .goloc.yml 中声明的自定义语言（自定义语言会覆盖 gocloc 的识别结果）
+func collectExtraFiles(root string, counted map[string]*gocloc.ClocFile, options *gocloc.ClocOptions, custom []*customLanguageMatcher) []FileStat {
+	lockLang := gocloc.NewLanguage(LockfileLanguage, []string{}, [][]string{})
+
+	customLangs := make([]*gocloc.Language, len(custom))
+	for i, m := range custom {
+		customLangs[i] = gocloc.NewLanguage(m.lang.Name, m.lang.LineComments, m.lang.BlockComments)
+	}
+
+	var stats []FileStat
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if options.ReNotMatchDir != nil && path != root && options.ReNotMatchDir.MatchString(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			relPath = path
+		}
+
+		var lang *gocloc.Language
+		for i, m := range custom {
+			if m.match(filepath.ToSlash(relPath)) {
+				lang = customLangs[i]
+				break
+			}
+		}
+		if lang == nil {
+			if _, ok := counted[path]; ok || !IsLockfile(info.Name()) {
+				return nil
+			}
+			lang = lockLang
+		}
+
+		clocFile := gocloc.AnalyzeFile(path, lang, options)
+		stats = append(stats, FileStat{
+			Path:     relPath,
+			Language: lang.Name,
+			Code:     int(clocFile.Code),
+			Comments: int(clocFile.Comments),
+			Blanks:   int(clocFile.Blanks),
+		})
+		return nil
+	})
+	return stats
+}
+
+// ValidateRepoURL 校验仓库地址为 http(s) URL
+// 仓库地址会作为参数传给 git，以 - 开头的值会被当作选项（如 --upload-pack）执行任意命令
+func ValidateRepoURL(repoURL string) error {
+	repoURL = strings.TrimSpace(repoURL)
+	if strings.HasPrefix(repoURL, "-") {
+		return fmt.Errorf("repository URL must not start with '-'")
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("repository URL must be an http(s) URL")
+	}
+	return nil
 }
 
 // cloneRepo clones a repository with the specified branch
 // Automatically uses HTTP_PROXY/HTTPS_PROXY from environment if set
-func cloneRepo(ctx context.Context, repoURL string, branch string, tmpDir string) error {
-	args := []string{"clone", "--depth=1", "--single-branch"}
+// 通过 --progress 获取克隆进度，tracker 可为 nil
+func cloneRepo(ctx context.Context, repoURL string, branch string, tmpDir string, tracker *Tracker) error {
+	args := []string{"clone", "--progress", "--depth=1", "--single-branch"}
 	if branch != "" {
 		args = append(args, "--branch", branch)
 	}
-	args = append(args, repoURL, tmpDir)
+	args = append(args, "--", repoURL, tmpDir)
 
 	// Log proxy settings if present
 	if proxy := os.Getenv("HTTPS_PROXY"); proxy != "" {
@@ -151,24 +425,88 @@ func cloneRepo(ctx context.Context, repoURL string, branch string, tmpDir string
 	// Inherit all environment variables (includes HTTP_PROXY, HTTPS_PROXY, etc.)
 	cmd.Env = os.Environ()
 
-	out, err := cmd.CombinedOutput()
+	// git clone 的输出（进度和错误信息）都在 stderr
+	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("git clone failed: %s, output: %s", err, strings.TrimSpace(string(out)))
+		return classifyCloneError(ctx, err, "")
+	}
+	if err := cmd.Start(); err != nil {
+		return classifyCloneError(ctx, err, "")
+	}
+	out := readCloneProgress(stderr, tracker)
+	if err := cmd.Wait(); err != nil {
+		return classifyCloneError(ctx, err, out)
 	}
 	return nil
 }
 
-// getRepoMeta fetches repository metadata from GitHub API
-func getRepoMeta(ctx context.Context, repoURL string, token string) (*RepoMeta, error) {
-	trimmed := strings.TrimSuffix(repoURL, ".git")
-	parts := strings.Split(trimmed, "/")
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid github url")
+// headCommit 返回克隆目录当前检出的提交
+func headCommit(ctx context.Context, dir string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", err
 	}
-	repo := parts[len(parts)-1]
-	owner := parts[len(parts)-2]
+	return strings.TrimSpace(string(out)), nil
+}
+
+// RepoHead 分支（或标签）在远端当前指向的提交
+type RepoHead struct {
+	Ref    string // 解析到的引用，如 refs/heads/main
+	Commit string
+}
+
+// ResolveHead 通过 git ls-remote 解析分支当前的提交，只交换引用列表，不下载仓库内容
+// branch 为空时解析远端的默认分支；与 git clone --branch 一致，同名时分支优先于标签
+func ResolveHead(ctx context.Context, repoURL string, branch string) (*RepoHead, error) {
+	ctx, cancel := context.WithTimeout(ctx, lsRemoteTimeout)
+	defer cancel()
 
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo)
+	args := []string{"ls-remote"}
+	if branch == "" {
+		args = append(args, "--symref", "--", repoURL, "HEAD")
+	} else {
+		args = append(args, "--", repoURL, "refs/heads/"+branch, "refs/tags/"+branch, "refs/tags/"+branch+"^{}")
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git ls-remote failed: %v", err)
+	}
+
+	refs := make(map[string]string)
+	var symref string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 2 {
+			continue
+		}
+		if target, ok := strings.CutPrefix(fields[0], "ref: "); ok {
+			symref = target
+			continue
+		}
+		refs[fields[1]] = fields[0]
+	}
+
+	if branch == "" {
+		commit, ok := refs["HEAD"]
+		if !ok {
+			return nil, fmt.Errorf("remote HEAD not found")
+		}
+		return &RepoHead{Ref: symref, Commit: commit}, nil
+	}
+	// 附注标签需要取 ^{} 解引用后的提交
+	for _, ref := range []string{"refs/heads/" + branch, "refs/tags/" + branch + "^{}", "refs/tags/" + branch} {
+		if commit, ok := refs[ref]; ok {
+			return &RepoHead{Ref: strings.TrimSuffix(ref, "^{}"), Commit: commit}, nil
+		}
+	}
+	return nil, fmt.Errorf("branch %q not found", branch)
+}
+
+// githubGet 请求 GitHub API，网络错误按超时/上游错误分类，调用方需关闭响应
+func githubGet(ctx context.Context, apiURL string, token string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, err
@@ -178,20 +516,71 @@ func getRepoMeta(ctx context.Context, repoURL string, token string) (*RepoMeta,
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	return doAPIRequest(ctx, req)
+}
 
+// doAPIRequest 发送 GitHub/GitLab API 请求，网络错误按超时/上游错误分类
+func doAPIRequest(ctx context.Context, req *http.Request) (*http.Response, error) {
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("api network error: %v", err)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, newAnalysisError(ErrorTimeout, "api request timed out: %v", err)
+		}
+		return nil, newAnalysisError(ErrorUpstream, "api network error: %v", err)
+	}
+	return resp, nil
+}
+
+// githubStatusError 按响应码分类 GitHub API 的错误，成功时返回 nil
+// notFoundHint 为 404 时附加的说明
+func githubStatusError(resp *http.Response, notFoundHint string) error {
+	return apiStatusError(resp, "github", notFoundHint)
+}
+
+// apiStatusError 按响应码分类 API 的错误，api 为错误信息中的来源名称
+func apiStatusError(resp *http.Response, api string, notFoundHint string) error {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusNotFound:
+		return newAnalysisError(ErrorNotFound, "%s api error: %s (%s)", api, resp.Status, notFoundHint)
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0":
+		return newAnalysisError(ErrorRateLimited, "%s api error: %s (rate limit exceeded)", api, resp.Status)
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusForbidden:
+		return newAnalysisError(ErrorAccessDenied, "%s api error: %s", api, resp.Status)
+	default:
+		return newAnalysisError(ErrorUpstream, "%s api error: %s", api, resp.Status)
+	}
+}
+
+// githubAPIBase GitHub API 地址，测试中替换为本地服务
+var githubAPIBase = "https://api.github.com"
+
+// getRepoMeta fetches repository metadata from GitHub API
+func getRepoMeta(ctx context.Context, repoURL string, token string) (*RepoMeta, error) {
+	trimmed := strings.TrimSuffix(repoURL, ".git")
+	parts := strings.Split(trimmed, "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid github url")
+	}
+	repo := parts[len(parts)-1]
+	owner := parts[len(parts)-2]
+
+	apiURL := fmt.Sprintf("%s/repos/%s/%s", githubAPIBase, owner, repo)
+	resp, err := githubGet(ctx, apiURL, token)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("github api error: %s", resp.Status)
+	if err := githubStatusError(resp, "repository not found or private"); err != nil {
+		return nil, err
 	}
 
 	var meta RepoMeta
 	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
-		return nil, fmt.Errorf("failed to decode api response: %v", err)
+		return nil, newAnalysisError(ErrorUpstream, "failed to decode api response: %v", err)
 	}
 
 	tokenStatus := "Anonymous"
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateRepoURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://github.com/gin-gonic/gin", true},
+		{"http://github.com/gin-gonic/gin.git", true},
+		{"  https://github.com/gin-gonic/gin", true},
+		{"--upload-pack=touch /tmp/x;", false},
+		{"-https://github.com/gin-gonic/gin", false},
+		{"github.com/gin-gonic/gin", false},
+		{"ssh://git@github.com/gin-gonic/gin", false},
+		{"file:///etc", false},
+		{"https://", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateRepoURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("ValidateRepoURL(%q) = %v, want ok=%v", tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestCountExcludedDirs(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"node_modules/a.js":          "let a = 1\n\n  // note\n",
+		"node_modules/pkg/vendor/b":  "x\ny", // 嵌套的排除目录归入外层规则
+		"node_modules/logo.png":      "\x89PNG\x00\x01",
+		"src/vendor/c.go":            "package c\n",
+		"src/main.go":                "package main\n",
+		".git/vendor/objects":        "ignored\n",
+		"dist/bundle.js":             "not excluded\n",
+		"node_modules/.bin/empty.sh": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(content), 0o644)
+	}
+
+	got := countExcludedDirs(root, []string{"node_modules", "vendor", "bower_components"})
+	want := []ExcludedDirStat{
+		{Pattern: "node_modules", Dirs: 1, FilteredStat: FilteredStat{Files: 3, Lines: 5, Code: 4}},
+		{Pattern: "vendor", Dirs: 1, FilteredStat: FilteredStat{Files: 1, Lines: 1, Code: 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("countExcludedDirs() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAnalysisOptionsNormalizesExcludeDirs(t *testing.T) {
+	a := Config{ExcludeDirs: []string{" vendor", "node_modules", "", "vendor ", "  "}}.AnalysisOptions()
+	b := Config{ExcludeDirs: []string{"node_modules", "vendor"}}.AnalysisOptions()
+
+	want := []string{"node_modules", "vendor"}
+	if !reflect.DeepEqual(a.ExcludeDirs, want) {
+		t.Fatalf("ExcludeDirs = %q, want %q", a.ExcludeDirs, want)
+	}
+	if BuildCacheKey("https://github.com/o/r", "abc", a) != BuildCacheKey("https://github.com/o/r", "abc", b) {
+		t.Error("equivalent exclude lists produced different cache keys")
+	}
+
+	// 同一缓存键下排除规则也必须一致：带空白的目录名同样被排除
+	re := buildExcludeDirRegex(a.ExcludeDirs)
+	for _, dir := range []string{"vendor/x.go", "src/node_modules/y.js"} {
+		if !re.MatchString(dir) {
+			t.Errorf("exclude regex does not match %q", dir)
+		}
+	}
+	if re.MatchString("src/main.go") || re.MatchString("vendored/x.go") {
+		t.Error("exclude regex matches a path it should keep")
+	}
+}
@@ -1,6 +1,10 @@
 package main
 
-import "strings"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 func BuildTree(files []FileStat, maxDepth int, projectName string) *Node {
 	if projectName == "" {
@@ -19,6 +23,7 @@ func BuildTree(files []FileStat, maxDepth int, projectName string) *Node {
 		current := root
 
 		addToStats(&current.Stats, file)
+		current.Files++
 		for i, part := range parts {
 			if i >= maxDepth {
 				break
@@ -41,12 +46,150 @@ func BuildTree(files []FileStat, maxDepth int, projectName string) *Node {
 			}
 			child := current.Children[part]
 			addToStats(&child.Stats, file)
+			child.Files++
 			current = child
 		}
 	}
 	return root
 }
 
+// BuildSubtree 生成 path 下 depth 层的目录树，path 为空时为整个仓库
+// path 为文件时返回该文件节点，不存在时返回 nil
+func BuildSubtree(files []FileStat, path string, depth int, projectName string) *Node {
+	path = strings.Trim(strings.ReplaceAll(path, "\\", "/"), "/")
+	if path == "" {
+		return BuildTree(files, depth, projectName)
+	}
+
+	prefix := path + "/"
+	var matched []FileStat
+	for _, file := range files {
+		cleanPath := strings.ReplaceAll(file.Path, "\\", "/")
+		if cleanPath == path || strings.HasPrefix(cleanPath, prefix) {
+			matched = append(matched, file)
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	parts := strings.Split(path, "/")
+	node := BuildTree(matched, len(parts)+depth, projectName)
+	for _, part := range parts {
+		node = node.Children[part]
+	}
+	return node
+}
+
+// TreeFormat 目录树的输出格式
+type TreeFormat string
+
+const (
+	TreeFormatMap     TreeFormat = "map"     // children 为以名称为键的对象
+	TreeFormatOrdered TreeFormat = "ordered" // children 为有序数组，见 OrderedNode
+)
+
+// TreeSort ordered 格式中子节点的排序方式：name 按名称升序，其余按指标降序，相同时按名称
+type TreeSort string
+
+const (
+	TreeSortName  TreeSort = "name"
+	TreeSortCode  TreeSort = "code"
+	TreeSortLines TreeSort = "lines"
+	TreeSortFiles TreeSort = "files"
+)
+
+// ParseTreeOptions 解析目录树格式和排序方式，为空时分别为 map 和 name
+func ParseTreeOptions(format string, sortBy string) (TreeFormat, TreeSort, error) {
+	f := TreeFormat(format)
+	switch f {
+	case "":
+		f = TreeFormatMap
+	case TreeFormatMap, TreeFormatOrdered:
+	default:
+		return "", "", fmt.Errorf("invalid tree format %q, expected map or ordered", format)
+	}
+	s := TreeSort(sortBy)
+	switch s {
+	case "":
+		s = TreeSortName
+	case TreeSortName, TreeSortCode, TreeSortLines, TreeSortFiles:
+	default:
+		return "", "", fmt.Errorf("invalid tree sort %q, expected name, code, lines or files", sortBy)
+	}
+	return f, s, nil
+}
+
+// OrderTree 将目录树转换为子节点有序的格式
+// parentLines、rootLines 为计算百分比用的父节点和根节点行数，不大于 0 时使用 node 自身的行数
+// （用于子目录树时传入实际的父目录和仓库行数）
+func OrderTree(node *Node, sortBy TreeSort, parentLines int, rootLines int) *OrderedNode {
+	if parentLines <= 0 {
+		parentLines = node.Stats.Lines
+	}
+	if rootLines <= 0 {
+		rootLines = node.Stats.Lines
+	}
+	return orderNode(node, sortBy, parentLines, rootLines)
+}
+
+func orderNode(node *Node, sortBy TreeSort, parentLines int, rootLines int) *OrderedNode {
+	ordered := &OrderedNode{
+		Name:            node.Name,
+		Type:            node.Type,
+		Path:            node.Path,
+		Language:        node.Language,
+		Stats:           node.Stats,
+		Files:           node.Files,
+		PercentOfParent: percentOf(node.Stats.Lines, parentLines),
+		PercentOfRoot:   percentOf(node.Stats.Lines, rootLines),
+		Children:        make([]*OrderedNode, 0, len(node.Children)),
+	}
+	for _, child := range node.Children {
+		ordered.Children = append(ordered.Children, orderNode(child, sortBy, node.Stats.Lines, rootLines))
+	}
+	sort.Slice(ordered.Children, func(i, j int) bool {
+		a, b := ordered.Children[i], ordered.Children[j]
+		if va, vb := a.sortValue(sortBy), b.sortValue(sortBy); va != vb {
+			return va > vb
+		}
+		return a.Name < b.Name
+	})
+	return ordered
+}
+
+func (n *OrderedNode) sortValue(sortBy TreeSort) int {
+	switch sortBy {
+	case TreeSortCode:
+		return n.Stats.Code
+	case TreeSortLines:
+		return n.Stats.Lines
+	case TreeSortFiles:
+		return n.Files
+	}
+	return 0
+}
+
+func percentOf(lines int, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(lines) / float64(total) * 100
+}
+
+// linesUnder 统计 dir 下文件的行数，dir 为空时为全部文件
+func linesUnder(files []FileStat, dir string) int {
+	lines := 0
+	prefix := dir + "/"
+	for _, f := range files {
+		cleanPath := strings.ReplaceAll(f.Path, "\\", "/")
+		if dir == "" || strings.HasPrefix(cleanPath, prefix) {
+			lines += f.Code + f.Comments + f.Blanks
+		}
+	}
+	return lines
+}
+
 func addToStats(s *Summary, f FileStat) {
 	s.Code += f.Code
 	s.Comments += f.Comments
@@ -54,6 +197,15 @@ func addToStats(s *Summary, f FileStat) {
 	s.Lines += (f.Code + f.Comments + f.Blanks)
 }
 
+// SummarizeFiles 汇总文件列表的行数
+func SummarizeFiles(files []FileStat) Summary {
+	var s Summary
+	for _, f := range files {
+		addToStats(&s, f)
+	}
+	return s
+}
+
 // CalculateLanguageStats 计算所有文件的语言统计（不受深度限制）
 func CalculateLanguageStats(files []FileStat) []LanguageStat {
 	langMap := make(map[string]*LanguageStat)
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testTreeFiles() []FileStat {
+	return []FileStat{
+		{Path: "main.go", Language: "Go", Code: 80, Comments: 10, Blanks: 10},
+		{Path: "src/a.go", Language: "Go", Code: 50, Comments: 5, Blanks: 5},
+		{Path: "src\\b\\c.go", Language: "Go", Code: 20, Comments: 5, Blanks: 5},
+		{Path: "src/b/d.go", Language: "Go", Code: 10, Comments: 0, Blanks: 0},
+	}
+}
+
+func childNames(n *OrderedNode) []string {
+	names := []string{}
+	for _, c := range n.Children {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func childNamed(n *OrderedNode, name string) *OrderedNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestBuildSubtree(t *testing.T) {
+	files := testTreeFiles()
+
+	src := BuildSubtree(files, "/src/", 1, "repo")
+	if src == nil || src.Path != "src" || src.Files != 3 || src.Stats.Lines != 100 {
+		t.Fatalf("subtree src = %+v", src)
+	}
+	b := src.Children["b"]
+	if b == nil || b.Files != 2 || len(b.Children) != 0 {
+		t.Errorf("src/b = %+v, want 2 files and no children at depth 1", b)
+	}
+
+	file := BuildSubtree(files, "src/a.go", 1, "repo")
+	if file == nil || file.Type != "file" || file.Stats.Code != 50 {
+		t.Errorf("subtree of a file = %+v", file)
+	}
+	if BuildSubtree(files, "missing", 1, "repo") != nil {
+		t.Error("subtree of a missing path is not nil")
+	}
+	// 前缀相同但不在目录下的路径不算
+	if BuildSubtree(files, "sr", 1, "repo") != nil {
+		t.Error("subtree matched a partial path segment")
+	}
+}
+
+func TestOrderTree(t *testing.T) {
+	files := testTreeFiles()
+	root := BuildTree(files, 3, "repo")
+
+	// 行数相同时按名称排序
+	byLines := OrderTree(root, TreeSortLines, 0, 0)
+	if got, want := childNames(byLines), []string{"main.go", "src"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sort=lines: %v, want %v", got, want)
+	}
+	if byLines.PercentOfRoot != 100 || byLines.Children[1].PercentOfRoot != 50 {
+		t.Errorf("percent_of_root = %v / %v, want 100 / 50", byLines.PercentOfRoot, byLines.Children[1].PercentOfRoot)
+	}
+
+	byFiles := OrderTree(root, TreeSortFiles, 0, 0)
+	if got, want := childNames(byFiles), []string{"src", "main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sort=files: %v, want %v", got, want)
+	}
+	src := childNamed(byFiles, "src")
+	if got, want := childNames(src), []string{"b", "a.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sort=files: %v, want %v", got, want)
+	}
+	if b := src.Children[0]; b.PercentOfParent != 40 || b.PercentOfRoot != 20 {
+		t.Errorf("src/b percents = %v / %v, want 40 / 20", b.PercentOfParent, b.PercentOfRoot)
+	}
+
+	byName := childNamed(OrderTree(root, TreeSortName, 0, 0), "src")
+	if got, want := childNames(byName), []string{"a.go", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sort=name: %v, want %v", got, want)
+	}
+
+	// 子目录树按实际的父目录和仓库行数计算百分比
+	sub := OrderTree(BuildSubtree(files, "src/b", 1, "repo"), TreeSortName, linesUnder(files, "src"), linesUnder(files, ""))
+	if sub.PercentOfParent != 40 || sub.PercentOfRoot != 20 {
+		t.Errorf("subtree percents = %v / %v, want 40 / 20", sub.PercentOfParent, sub.PercentOfRoot)
+	}
+}
+
+func TestParseTreeOptions(t *testing.T) {
+	format, sortBy, err := ParseTreeOptions("", "")
+	if err != nil || format != TreeFormatMap || sortBy != TreeSortName {
+		t.Errorf("defaults = %q, %q, %v", format, sortBy, err)
+	}
+	if _, _, err := ParseTreeOptions("list", ""); err == nil {
+		t.Error("invalid format accepted")
+	}
+	if _, _, err := ParseTreeOptions("ordered", "size"); err == nil {
+		t.Error("invalid sort accepted")
+	}
+}
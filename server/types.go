@@ -8,6 +8,16 @@ type FileStat struct {
 	Blanks   int    `json:"blanks"`
 }
 
+// RepoStats 一次仓库分析的完整结果，作为缓存单元
+type RepoStats struct {
+	Branch       string            `json:"branch"`        // 分析的分支（未指定时为仓库默认分支）
+	Commit       string            `json:"commit"`        // 分析时的提交 SHA
+	AnalyzedAt   int64             `json:"analyzed_at"`   // 分析完成时间（Unix 秒），用于判断缓存是否过期
+	Files        []FileStat        `json:"files"`         // 未按分类过滤的全部文件
+	ExcludedDirs []ExcludedDirStat `json:"excluded_dirs"` // 分析时被排除目录的统计
+	RepoConfig   *RepoConfig       `json:"repo_config"`   // 仓库自带的配置，没有时为 nil
+}
+
 type Summary struct {
 	Lines    int `json:"lines"`
 	Code     int `json:"code"`
@@ -32,9 +42,24 @@ type Node struct {
 	Path     string           `json:"path"`
 	Language string           `json:"language,omitempty"`
 	Stats    Summary          `json:"stats"`
+	Files    int              `json:"files"` // 节点下的文件数（不受深度限制）
 	Children map[string]*Node `json:"children"`
 }
 
+// OrderedNode 子节点为有序数组的目录树节点，输出稳定，便于比较两次结果
+// 百分比按行数计算（0~100）
+type OrderedNode struct {
+	Name            string         `json:"name"`
+	Type            string         `json:"type"`
+	Path            string         `json:"path"`
+	Language        string         `json:"language,omitempty"`
+	Stats           Summary        `json:"stats"`
+	Files           int            `json:"files"`
+	PercentOfParent float64        `json:"percent_of_parent"`
+	PercentOfRoot   float64        `json:"percent_of_root"`
+	Children        []*OrderedNode `json:"children"`
+}
+
 // Response 统一API响应结构
 type Response struct {
 	Code    int         `json:"code"`    // 响应状态码: 0 表示成功, 非0表示失败
@@ -42,12 +67,165 @@ type Response struct {
 	Message string      `json:"message"` // 响应消息
 }
 
+// LockfileStat 依赖锁文件统计
+type LockfileStat struct {
+	Skipped bool `json:"skipped"` // 是否被排除在统计之外
+	Files   int  `json:"files"`
+	Lines   int  `json:"lines"`
+}
+
+// FilteredStat 被排除/过滤内容的计数
+type FilteredStat struct {
+	Files int `json:"files"`
+	Lines int `json:"lines"`
+	Code  int `json:"code"`
+}
+
+// Add 累加一个文件
+func (s *FilteredStat) Add(f FileStat) {
+	s.Files++
+	s.Lines += f.Code + f.Comments + f.Blanks
+	s.Code += f.Code
+}
+
+// ExcludedDirStat 被排除目录的统计（按排除规则汇总）
+// 不做语言识别：lines 为全部行数，code 为非空行数（包括注释），二进制文件不计入
+type ExcludedDirStat struct {
+	Pattern string `json:"pattern"` // 排除规则
+	Dirs    int    `json:"dirs"`    // 命中的目录数
+	FilteredStat
+}
+
+// ExcludedPatternStat 被路径规则排除的文件统计
+type ExcludedPatternStat struct {
+	Pattern string `json:"pattern"`
+	FilteredStat
+}
+
+// ExcludedCategoryStat 被过滤分类的统计
+type ExcludedCategoryStat struct {
+	Category LanguageCategory `json:"category"`
+	FilteredStat
+}
+
+// ExcludedReport 被排除/过滤内容的汇总
+type ExcludedReport struct {
+	Dirs        []ExcludedDirStat      `json:"dirs"`
+	Patterns    []ExcludedPatternStat  `json:"patterns"`     // 被 exclude_patterns 排除的文件
+	NotIncluded FilteredStat           `json:"not_included"` // 未命中 include_patterns 的文件
+	Categories  []ExcludedCategoryStat `json:"categories"`
+}
+
+// AnalyzeRequest 分析请求
+type AnalyzeRequest struct {
+	RepoURL  string `json:"repo_url"`
+	Branch   string `json:"branch"`
+	MaxDepth int    `json:"max_depth"`
+	Priority string `json:"priority"` // 分析队列中的优先级：interactive（默认）、batch、background
+	// 目录树格式：map（默认，data 中 children 为以名称为键的对象）或 ordered（tree 中 children 为有序数组）
+	TreeFormat string `json:"tree_format"`
+	TreeSort   string `json:"tree_sort"` // ordered 格式的排序：name（默认）、code、lines、files
+	// 过滤选项覆盖，传入的字段替换全局配置，仅对本次请求生效
+	FilterOptions
+}
+
 // AnalyzeResult 分析结果数据结构
 type AnalyzeResult struct {
 	Source    string         `json:"source"`
 	Repo      string         `json:"repo"`
 	Branch    string         `json:"branch"`
+	Commit    string         `json:"commit,omitempty"` // 分析结果对应的提交
+	Age       int64          `json:"age_seconds"`      // 结果距今的秒数，实时分析为 0
 	Timestamp int64          `json:"timestamp"`
-	Data      *Node          `json:"data"`
-	Languages []LanguageStat `json:"languages"` // 完整的语言统计（不受深度限制）
+	Data      *Node          `json:"data,omitempty"` // map 格式的目录树
+	Tree      *OrderedNode   `json:"tree,omitempty"` // ordered 格式的目录树
+	Languages []LanguageStat `json:"languages"`      // 完整的语言统计（不受深度限制）
+	Lockfiles LockfileStat   `json:"lockfiles"`      // 依赖锁文件统计
+	Excluded  ExcludedReport `json:"excluded"`       // 被排除/过滤的内容
+	// 仓库自带的配置（.goloc.yml / .golocignore），没有或未启用时省略
+	RepoConfig *RepoConfigSummary `json:"repo_config,omitempty"`
+}
+
+// BatchTarget 批量分析中的一个仓库
+type BatchTarget struct {
+	RepoURL string `json:"repo_url"`
+	Branch  string `json:"branch"` // 为空时使用仓库默认分支
+}
+
+// BatchAnalyzeRequest 批量分析请求
+type BatchAnalyzeRequest struct {
+	Repos    []BatchTarget `json:"repos"`
+	Priority string        `json:"priority"` // 分析队列中的优先级：batch（默认）或 background，interactive 按 batch 处理
+	// 过滤选项覆盖，对所有仓库生效
+	FilterOptions
+}
+
+// BatchRepoResult 批量分析中单个仓库的结果，失败时只有 Error
+type BatchRepoResult struct {
+	Repo      string         `json:"repo"`
+	Branch    string         `json:"branch"` // 实际分析的分支，未指定时为默认分支
+	Commit    string         `json:"commit,omitempty"`
+	Source    string         `json:"source,omitempty"`
+	Age       int64          `json:"age_seconds"`
+	Files     int            `json:"files"`
+	Summary   Summary        `json:"summary"`
+	Languages []LanguageStat `json:"languages,omitempty"`
+	Error     *AnalysisError `json:"error,omitempty"`
+}
+
+// BatchResult 批量分析结果，汇总只包含成功的仓库
+type BatchResult struct {
+	Repos     []BatchRepoResult `json:"repos"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Files     int               `json:"files"`
+	Summary   Summary           `json:"summary"`
+	Languages []LanguageStat    `json:"languages"` // 基于所有仓库文件的语言统计
+	Timestamp int64             `json:"timestamp"`
+}
+
+// OrgAnalyzeRequest 组织/用户级分析请求
+type OrgAnalyzeRequest struct {
+	Owner            string   `json:"owner"`              // GitHub 组织或用户名，也可以是其主页地址或 GitLab 群组地址
+	IncludeForks     bool     `json:"include_forks"`      // 是否包含 fork 的仓库，默认不包含
+	IncludeArchived  bool     `json:"include_archived"`   // 是否包含已归档的仓库，默认不包含
+	Topics           []string `json:"topics"`             // 只分析带有其中任意一个 topic 的仓库
+	NameRegex        string   `json:"name_regex"`         // 只分析名称匹配的仓库
+	ExcludeNameRegex string   `json:"exclude_name_regex"` // 排除名称匹配的仓库
+	Priority         string   `json:"priority"`           // 分析队列中的优先级：batch（默认）或 background，interactive 按 batch 处理
+	// 过滤选项覆盖，对所有仓库生效
+	FilterOptions
+}
+
+// OrgResult 组织/用户级分析结果
+type OrgResult struct {
+	Owner     string `json:"owner"`
+	Listed    int    `json:"listed"`    // 组织/用户的仓库总数
+	Matched   int    `json:"matched"`   // 满足筛选条件并参与分析的仓库数
+	Truncated bool   `json:"truncated"` // 满足条件的仓库超过单次分析上限，只分析了前面的部分
+	*BatchResult
+}
+
+// SubtreeResult 按需展开的子目录树
+type SubtreeResult struct {
+	Repo   string       `json:"repo"`
+	Ref    string       `json:"ref"`
+	Commit string       `json:"commit,omitempty"`
+	Path   string       `json:"path"`
+	Depth  int          `json:"depth"`
+	Source string       `json:"source"`
+	Data   *Node        `json:"data,omitempty"` // map 格式的目录树
+	Tree   *OrderedNode `json:"tree,omitempty"` // ordered 格式的目录树
+}
+
+// FileListResult 文件列表的一页
+type FileListResult struct {
+	Repo       string      `json:"repo"`
+	Ref        string      `json:"ref"`
+	Commit     string      `json:"commit,omitempty"`
+	Source     string      `json:"source"`
+	Total      int         `json:"total"`   // 满足条件的文件总数
+	Summary    Summary     `json:"summary"` // 满足条件的文件的汇总
+	Files      []FileEntry `json:"files"`
+	NextCursor string      `json:"next_cursor,omitempty"` // 还有下一页时返回
 }